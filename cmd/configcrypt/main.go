@@ -0,0 +1,92 @@
+// Command configcrypt encrypts and decrypts ENC(...) config values.
+//
+// Usage:
+//
+//	configcrypt genkey > app.key
+//	configcrypt -keyfile app.key encrypt 'db password'
+//	configcrypt -keyfile app.key decrypt 'ENC(...)'
+//
+// Without -key or -keyfile the key comes from $LITEGO_CONFIG_KEY or
+// $LITEGO_CONFIG_KEYFILE, like it does for config. A missing value is read
+// from the first line of stdin, which keeps it out of the shell history.
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"litego/config"
+	"os"
+	"strings"
+)
+
+func main() {
+	key := flag.String("key", "", "base64 encoded key")
+	keyfile := flag.String("keyfile", "", "file holding the key")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [-key k | -keyfile f] genkey | encrypt [value] | decrypt [value]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cmd := flag.Arg(0)
+	if cmd == "genkey" {
+		k, err := config.GenerateSecretKey()
+		exitOnError(err)
+		fmt.Println(base64.StdEncoding.EncodeToString(k))
+		return
+	}
+
+	var (
+		k   []byte
+		err error
+	)
+	switch {
+	case *key != "":
+		k, err = base64.StdEncoding.DecodeString(*key)
+		if err == nil {
+			err = config.SetSecretKey(k)
+		}
+	case *keyfile != "":
+		k, err = config.LoadSecretKeyFile(*keyfile)
+	default:
+		k, err = config.SecretKey()
+	}
+	exitOnError(err)
+
+	value := flag.Arg(1)
+	if flag.NArg() < 2 {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			exitOnError(fmt.Errorf("reading value from stdin: %v", err))
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+
+	switch cmd {
+	case "encrypt":
+		out, err := config.EncryptValue(k, value)
+		exitOnError(err)
+		fmt.Println(out)
+	case "decrypt":
+		out, err := config.DecryptValue(k, value)
+		exitOnError(err)
+		fmt.Println(out)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "configcrypt:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,94 @@
+// Command migrate applies the schema migrations of a directory.
+//
+// Usage:
+//
+//	migrate -config app.ini -dir migrations status
+//	migrate -config app.ini -dir migrations up
+//	migrate -config app.ini -dir migrations down [n]
+//	migrate -config app.ini -dir migrations -dry-run to 12
+//
+// The connection is read from the -section of the config file, see
+// mysqlz.Options.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"litego/config"
+	"litego/mysqlz"
+	"litego/mysqlz/migrate"
+	"os"
+	"strconv"
+)
+
+func main() {
+	cfgFile := flag.String("config", "config.ini", "config file")
+	section := flag.String("section", "mysql", "config section of the connection")
+	dir := flag.String("dir", "migrations", "directory of the migration files")
+	dryRun := flag.Bool("dry-run", false, "print the statements instead of running them")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] status | up | down [n] | to version\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.NewConfig(config.IniProtocol, *cfgFile)
+	exitOnError(err)
+	opts, err := mysqlz.OptionsFromConfig(cfg, *section)
+	exitOnError(err)
+	opts.ParseTime = true
+	pool, err := mysqlz.NewMysqlConnPoolOptions(opts)
+	exitOnError(err)
+	defer pool.ClosePool()
+
+	m := migrate.New(pool)
+	m.DryRun = *dryRun
+	exitOnError(m.LoadDir(*dir))
+
+	ctx := context.Background()
+	switch flag.Arg(0) {
+	case "status":
+		list, err := m.Status(ctx)
+		exitOnError(err)
+		for _, s := range list {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%6d  %-40s %s\n", s.Version, s.Name, state)
+		}
+	case "up":
+		exitOnError(m.Up(ctx))
+	case "down":
+		n := 1
+		if flag.NArg() > 1 {
+			n, err = strconv.Atoi(flag.Arg(1))
+			exitOnError(err)
+		}
+		exitOnError(m.Down(ctx, n))
+	case "to":
+		if flag.NArg() < 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		v, err := strconv.ParseInt(flag.Arg(1), 10, 64)
+		exitOnError(err)
+		exitOnError(m.To(ctx, v))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		os.Exit(1)
+	}
+}
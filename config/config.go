@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"io"
 )
 
 const (
@@ -10,6 +11,7 @@ const (
 )
 
 type ConfigAdapter interface {
+	Parse(r io.Reader) (Configurer, error)
 	ParseFile(filename string) (Configurer, error)
 	ParseData(data []byte) (Configurer, error)
 }
@@ -52,3 +54,12 @@ func NewConfigData(adapterName string, data []byte) (Configurer, error) {
 	}
 	return adapter.ParseData(data)
 }
+
+// adapterName is ini, r is read to the end.
+func NewConfigReader(adapterName string, r io.Reader) (Configurer, error) {
+	adapter, ok := adapters[adapterName]
+	if !ok {
+		return nil, fmt.Errorf("config: unknown adaptername %q", adapterName)
+	}
+	return adapter.Parse(r)
+}
@@ -6,48 +6,131 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
-	"path"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
-	"time"
 	"unicode"
 )
 
 var (
-	iniComment      = []byte{';'} // comment
-	iniEmpty        = []byte{}    // empty
-	iniEqual        = []byte{'='} // equal
-	iniDQuote       = []byte{'"'} // quote
-	iniSectionStart = []byte{'['} // section start
-	iniSectionEnd   = []byte{']'} // section end
-	iniLineBreak    = "\n"        // new line
+	iniComment      = []byte{';'}  // comment
+	iniHashComment  = []byte{'#'}  // comment
+	iniEmpty        = []byte{}     // empty
+	iniEqual        = []byte{'='}  // equal
+	iniDQuote       = []byte{'"'}  // quote
+	iniSQuote       = []byte{'\''} // literal quote
+	iniSectionStart = []byte{'['}  // section start
+	iniSectionEnd   = []byte{']'}  // section end
+	iniInherit      = []byte{':'}  // [section : base]
+	iniContinue     = []byte{'\\'} // continuation line
+	iniLineBreak    = "\n"         // new line
+	iniInclude      = "include"    // include = other.ini
+	iniBareValue    = "true"       // value of a key without "="
 )
 
 type IniConfigAdapter struct {
 }
 
+func (ini *IniConfigAdapter) Parse(r io.Reader) (Configurer, error) {
+	return ini.parse(r, "")
+}
+
 func (ini *IniConfigAdapter) ParseFile(name string) (Configurer, error) {
 	file, err := os.Open(name)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
+	return ini.parse(file, name)
+}
+
+func (ini *IniConfigAdapter) ParseData(data []byte) (Configurer, error) {
+	return ini.Parse(bytes.NewReader(data))
+}
 
+// parse reads r; name is the file r was opened from, "" for other readers.
+// Includes are relative to the directory of name.
+func (ini *IniConfigAdapter) parse(r io.Reader, name string) (Configurer, error) {
 	cfg := &IniConfigurer{
-		file.Name(),
-		make(map[string]map[string]string),
-		make(map[string]string),
-		make(map[string]string),
-		sync.RWMutex{},
+		filename:       name,
+		data:           make(map[string]map[string]string),
+		sectionComment: make(map[string]string),
+		keyComment:     make(map[string]string),
+		keys:           make(map[string][]string),
+	}
+	p := &iniParser{
+		cfg:       cfg,
+		parents:   make(map[string]iniParent),
+		including: make(map[string]bool),
+	}
+	if name != "" {
+		abs, err := filepath.Abs(name)
+		if err != nil {
+			return nil, err
+		}
+		p.including[abs] = true
+	}
+	if err := p.parse(r, name, true); err != nil {
+		return nil, err
+	}
+	if err := p.inherit(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+type iniParent struct {
+	name string
+	file string
+	line int
+}
+
+// iniParser reads one file and its includes into cfg.
+type iniParser struct {
+	cfg       *IniConfigurer
+	parents   map[string]iniParent // section : base section
+	including map[string]bool      // files being parsed, to stop include loops
+}
+
+func (p *iniParser) errorf(file string, line int, format string, v ...interface{}) error {
+	if file == "" {
+		file = "<data>"
+	}
+	return fmt.Errorf("config: %s:%d: %s", file, line, fmt.Sprintf(format, v...))
+}
+
+func (p *iniParser) parseFile(name string) error {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+	if p.including[abs] {
+		return fmt.Errorf("config: %s: include loop", name)
+	}
+	p.including[abs] = true
+	defer delete(p.including, abs)
+
+	file, err := os.Open(name)
+	if err != nil {
+		return err
 	}
-	cfg.Lock()
-	defer cfg.Unlock()
+	defer file.Close()
+
+	p.cfg.includes = append(p.cfg.includes, name)
+	return p.parse(file, name, false)
+}
 
-	var commentBuf bytes.Buffer
-	buf := bufio.NewReader(file)
+// parse reads the lines of one file; top keeps them for SaveTo.
+func (p *iniParser) parse(r io.Reader, name string, top bool) error {
+	var (
+		commentBuf bytes.Buffer
+		section    string
+		lineno     int
+	)
+	buf := bufio.NewReader(r)
 
 	// check the BOM
 	head, err := buf.Peek(3)
@@ -57,80 +140,243 @@ func (ini *IniConfigAdapter) ParseFile(name string) (Configurer, error) {
 		}
 	}
 
-	var section string
-	for {
-		line, _, err := buf.ReadLine()
-
+	for eof := false; !eof; {
+		line, err := buf.ReadBytes('\n')
 		if err == io.EOF {
+			eof = true
+		} else if err != nil {
+			return err
+		}
+		if eof && len(line) == 0 {
 			break
 		}
+		lineno++
+		start := lineno
+		raw := []string{strings.TrimRight(string(line), "\r\n")}
+
+		line = bytes.TrimSpace(line)
+
+		// a trailing backslash joins the next line
+		for bytes.HasSuffix(line, iniContinue) && !eof {
+			next, err := buf.ReadBytes('\n')
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+			lineno++
+			raw = append(raw, strings.TrimRight(string(next), "\r\n"))
+			line = append(bytes.TrimRight(line[:len(line)-1], " \t"), ' ')
+			line = append(line, bytes.TrimSpace(next)...)
+		}
+
+		// only the top file is kept for SaveTo, includes stay untouched
+		ln := &iniLine{raw: strings.Join(raw, iniLineBreak), section: section}
+		if top {
+			p.cfg.lines = append(p.cfg.lines, ln)
+		}
 
 		if bytes.Equal(line, iniEmpty) {
+			// a comment belongs to the key or section directly below it
+			commentBuf.Reset()
 			continue
 		}
 
-		line = bytes.TrimSpace(line)
-
-		if bytes.HasPrefix(line, iniComment) {
-			line = bytes.TrimLeft(line, string(iniComment))
+		if bytes.HasPrefix(line, iniComment) || bytes.HasPrefix(line, iniHashComment) {
+			line = bytes.TrimLeft(line, string(iniComment)+string(iniHashComment))
 			line = bytes.TrimLeftFunc(line, unicode.IsSpace)
 			commentBuf.Write(line)
 			commentBuf.WriteByte('\n')
 			continue
 		}
 
-		if bytes.HasPrefix(line, iniSectionStart) && bytes.HasSuffix(line, iniSectionEnd) {
-			section = strings.ToLower(string(line[1 : len(line)-1])) // section name case insensitive
+		if bytes.HasPrefix(line, iniSectionStart) {
+			end := bytes.Index(line, iniSectionEnd)
+			if end < 0 || !isComment(line[end+1:]) {
+				return p.errorf(name, start, "bad section header %q", line)
+			}
+			header := line[1:end]
+			var base string
+			if i := bytes.Index(header, iniInherit); i >= 0 {
+				base = strings.ToLower(string(bytes.TrimSpace(header[i+1:])))
+				header = header[:i]
+			}
+			section = strings.ToLower(string(bytes.TrimSpace(header))) // section name case insensitive
+			if section == "" {
+				return p.errorf(name, start, "empty section name")
+			}
+			ln.section = section
+			ln.header = true
+			if base != "" {
+				if base == section {
+					return p.errorf(name, start, "section %q inherits itself", section)
+				}
+				p.parents[section] = iniParent{base, name, start}
+			}
 			if commentBuf.Len() > 0 {
-				cfg.sectionComment[section] = commentBuf.String()
+				p.cfg.sectionComment[section] = commentBuf.String()
 				commentBuf.Reset()
 			}
-			if _, ok := cfg.data[section]; !ok {
-				cfg.data[section] = make(map[string]string)
+			p.cfg.addSection(section)
+			continue
+		}
+
+		var key, val string
+		eq := bytes.Index(line, iniEqual)
+		if ci := indexComment(line); eq < 0 || (ci >= 0 && ci < eq) {
+			if ci >= 0 {
+				line = line[:ci]
+			}
+			key = strings.ToLower(string(bytes.TrimSpace(line)))
+			val = iniBareValue
+		} else {
+			key = strings.ToLower(string(bytes.TrimSpace(line[:eq]))) // key name case insensitive
+			if val, err = parseIniValue(bytes.TrimSpace(line[eq+1:])); err != nil {
+				return p.errorf(name, start, "key %q: %v", key, err)
+			}
+			if val, err = decryptSecret(val); err != nil {
+				return p.errorf(name, start, "key %q: %v", key, err)
 			}
+		}
+		if key == "" {
+			return p.errorf(name, start, "missing key name in %q", line)
+		}
+
+		if key == iniInclude {
+			include := val
+			if !filepath.IsAbs(include) {
+				include = filepath.Join(filepath.Dir(name), include)
+			}
+			if err := p.parseFile(include); err != nil {
+				if os.IsNotExist(err) {
+					return p.errorf(name, start, "include %s: %v", val, err)
+				}
+				return err
+			}
+			commentBuf.Reset()
 			continue
 		}
 
-		if _, ok := cfg.data[section]; !ok {
-			cfg.data[section] = make(map[string]string)
+		p.cfg.setValue(section, key, val)
+		ln.key = key
+		if commentBuf.Len() > 0 {
+			p.cfg.keyComment[joinKey(section, key)] = commentBuf.String()
+			commentBuf.Reset()
 		}
-		keyValue := bytes.SplitN(line, iniEqual, 2)
+	}
+	return nil
+}
 
-		key := string(bytes.TrimSpace(keyValue[0])) // key name case insensitive
-		key = strings.ToLower(key)
+// inherit copies the keys of base sections into the sections that extend
+// them, keeping keys the section sets itself.
+func (p *iniParser) inherit() error {
+	done := make(map[string]bool)
+	var resolve func(section string, seen map[string]bool) error
+	resolve = func(section string, seen map[string]bool) error {
+		parent, ok := p.parents[section]
+		if !ok || done[section] {
+			return nil
+		}
+		if seen[section] {
+			return p.errorf(parent.file, parent.line, "inheritance loop at section %q", section)
+		}
+		seen[section] = true
 
-		if len(keyValue) != 2 {
-			return nil, errors.New("read the content error: \"" + string(line) + "\", should key = val")
+		base, ok := p.cfg.data[parent.name]
+		if !ok {
+			return p.errorf(parent.file, parent.line, "section %q extends unknown section %q", section, parent.name)
+		}
+		if err := resolve(parent.name, seen); err != nil {
+			return err
 		}
-		val := bytes.TrimSpace(keyValue[1])
-		if bytes.HasPrefix(val, iniDQuote) {
-			val = bytes.Trim(val, `"`)
+		for key, val := range base {
+			if _, ok := p.cfg.data[section][key]; !ok {
+				p.cfg.setValue(section, key, val)
+			}
 		}
+		done[section] = true
+		return nil
+	}
 
-		cfg.data[section][key] = string(val)
-		if commentBuf.Len() > 0 {
-			cfg.keyComment[section+"."+key] = commentBuf.String()
-			commentBuf.Reset()
+	for section := range p.parents {
+		if err := resolve(section, make(map[string]bool)); err != nil {
+			return err
 		}
 	}
-	return cfg, nil
+	return nil
 }
 
-func (ini *IniConfigAdapter) ParseData(data []byte) (Configurer, error) {
-	tmpName := path.Join(os.TempDir(), "iniconfig", fmt.Sprintf("%d", time.Now().Nanosecond()))
-	os.MkdirAll(path.Dir(tmpName), os.ModePerm)
-	if err := ioutil.WriteFile(tmpName, data, 0655); err != nil {
-		return nil, err
+// isComment reports whether rest is empty or only a comment.
+func isComment(rest []byte) bool {
+	rest = bytes.TrimSpace(rest)
+	return len(rest) == 0 || bytes.HasPrefix(rest, iniComment) || bytes.HasPrefix(rest, iniHashComment)
+}
+
+// indexComment returns the start of an inline comment: a ; or # at the line
+// start or after white space.
+func indexComment(b []byte) int {
+	for i, c := range b {
+		if (c == ';' || c == '#') && (i == 0 || b[i-1] == ' ' || b[i-1] == '\t') {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseIniValue strips inline comments and unquotes val. Double quoted
+// values understand \\, \", \n, \r and \t; single quoted values are taken
+// literally.
+func parseIniValue(val []byte) (string, error) {
+	if !bytes.HasPrefix(val, iniDQuote) && !bytes.HasPrefix(val, iniSQuote) {
+		if i := indexComment(val); i >= 0 {
+			val = val[:i]
+		}
+		return string(bytes.TrimSpace(val)), nil
+	}
+
+	quote := val[0]
+	var out bytes.Buffer
+	for i := 1; i < len(val); i++ {
+		c := val[i]
+		switch {
+		case c == quote:
+			if !isComment(val[i+1:]) {
+				return "", fmt.Errorf("unexpected text after closing quote: %q", val[i+1:])
+			}
+			return out.String(), nil
+		case c == '\\' && quote == '"':
+			if i+1 == len(val) {
+				return "", errors.New("unterminated escape")
+			}
+			i++
+			switch val[i] {
+			case 'n':
+				out.WriteByte('\n')
+			case 'r':
+				out.WriteByte('\r')
+			case 't':
+				out.WriteByte('\t')
+			case '\\', '"', '\'':
+				out.WriteByte(val[i])
+			default:
+				return "", fmt.Errorf("unknown escape \\%c", val[i])
+			}
+		default:
+			out.WriteByte(c)
+		}
 	}
-	defer os.Remove(tmpName)
-	return ini.ParseFile(tmpName)
+	return "", errors.New("missing closing quote")
 }
 
 type IniConfigurer struct {
 	filename       string
+	includes       []string
 	data           map[string]map[string]string // section.key = val
 	sectionComment map[string]string            // section : comment
 	keyComment     map[string]string
+	sections       []string            // sections in file order
+	keys           map[string][]string // section : keys in file order
+	lines          []*iniLine          // top file as written, for SaveTo
 	sync.RWMutex
 }
 
@@ -246,33 +492,104 @@ func (c *IniConfigurer) GetStrings(key string, v ...string) []string {
 	return val
 }
 
+// Files returns the files the configuration was read from.
+func (c *IniConfigurer) Files() []string {
+	c.RLock()
+	defer c.RUnlock()
+	if c.filename == "" {
+		return append([]string(nil), c.includes...)
+	}
+	return append([]string{c.filename}, c.includes...)
+}
+
+// Reload parses the file again and swaps the data in place. It returns a
+// snapshot of the previous data and the section.key names that changed.
+func (c *IniConfigurer) Reload() (Configurer, []string, error) {
+	if c.filename == "" {
+		return nil, nil, errors.New("config: Reload of data not read from a file")
+	}
+	cfg, err := (&IniConfigAdapter{}).ParseFile(c.filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	nc := cfg.(*IniConfigurer)
+
+	c.Lock()
+	old := &IniConfigurer{
+		filename:       c.filename,
+		includes:       c.includes,
+		data:           c.data,
+		sectionComment: c.sectionComment,
+		keyComment:     c.keyComment,
+		sections:       c.sections,
+		keys:           c.keys,
+		lines:          c.lines,
+	}
+	c.data = nc.data
+	c.includes = nc.includes
+	c.sectionComment = nc.sectionComment
+	c.keyComment = nc.keyComment
+	c.sections = nc.sections
+	c.keys = nc.keys
+	c.lines = nc.lines
+	c.Unlock()
+
+	return old, diffData(old.data, nc.data), nil
+}
+
+// diffData returns the sorted section.key names whose value differs.
+func diffData(old, new map[string]map[string]string) []string {
+	var changed []string
+	for section, kv := range old {
+		for key, val := range kv {
+			if nv, ok := new[section][key]; !ok || nv != val {
+				changed = append(changed, joinKey(section, key))
+			}
+		}
+	}
+	for section, kv := range new {
+		for key := range kv {
+			if _, ok := old[section][key]; !ok {
+				changed = append(changed, joinKey(section, key))
+			}
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
 // section.key
 func (c *IniConfigurer) getdata(section_key string) string {
+	val, _ := c.Lookup(section_key)
+	return val
+}
+
+// Lookup returns the value of section.key and whether it is set.
+func (c *IniConfigurer) Lookup(section_key string) (string, bool) {
 	c.RLock()
 	defer c.RUnlock()
 
 	if len(section_key) == 0 {
-		return ""
+		return "", false
 	}
 
-	var (
-		section, key string
-		keys         []string = strings.Split(strings.ToLower(section_key), ".")
-	)
-
-	if len(keys) != 2 {
-		panic(fmt.Sprintf("ini Set key not (session.key), error = %s", section_key))
-	} else {
-		section = keys[0]
-		key = keys[1]
+	// the key is what follows the longest section name that holds it, so
+	// [server.tls] cert reads as server.tls.cert and a dotted key name
+	// [db] read.timeout as db.read.timeout
+	section_key = strings.ToLower(section_key)
+	for i := strings.LastIndex(section_key, "."); i >= 0; i = strings.LastIndex(section_key[:i], ".") {
+		if v, ok := c.data[section_key[:i]]; ok {
+			if vv, ok := v[section_key[i+1:]]; ok {
+				return vv, true
+			}
+		}
 	}
 
-	if v, ok := c.data[section]; ok {
-		if vv, ok := v[key]; ok {
-			return vv
-		}
+	// keys before the first section header
+	if v, ok := c.data[""][section_key]; ok {
+		return v, true
 	}
-	return ""
+	return "", false
 }
 
 func init() {
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var iniSyntaxData = `appname = litego ; global key
+# hash comment
+[base]
+host = 127.0.0.1
+port = 3306
+timeout = 5s
+
+[prod : base]
+host = db.example.com # inline comment
+read.timeout = 10s
+greeting = "hello; \"world\"\n"
+literal = 'C:\path #1'
+hosts = a, \
+        b, \
+        c
+skipssl
+`
+
+func TestIniSyntax(t *testing.T) {
+	iniconf, err := NewConfigData(IniProtocol, []byte(iniSyntaxData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]string{
+		"appname":           "litego",
+		"base.host":         "127.0.0.1",
+		"prod.host":         "db.example.com",
+		"prod.port":         "3306",
+		"prod.timeout":      "5s",
+		"prod.read.timeout": "10s",
+		"prod.greeting":     "hello; \"world\"\n",
+		"prod.literal":      `C:\path #1`,
+		"prod.hosts":        "a, b, c",
+		"prod.skipssl":      "true",
+	} {
+		if val := iniconf.GetString(key); val != want {
+			t.Errorf("%s = %q, want %q", key, val, want)
+		}
+	}
+	if hosts := iniconf.GetStrings("prod.hosts"); len(hosts) != 3 {
+		t.Errorf("prod.hosts = %q", hosts)
+	}
+}
+
+func TestIniSyntaxErrors(t *testing.T) {
+	for data, want := range map[string]string{
+		"[server]\nname = \"unterminated\n":   ":2: key \"name\": missing closing quote",
+		"[server\n":                           ":1: bad section header",
+		"\n\n[a : missing]\n":                 ":3: section \"a\" extends unknown section \"missing\"",
+		"[a : b]\nx = 1\n[b : a]\n":           "inheritance loop",
+		"[a]\nx = \"v\" trailing\n":           ":2: key \"x\": unexpected text after closing quote",
+		"[a]\nx = \"bad \\q escape\"\n":       ":2: key \"x\": unknown escape",
+		"[a]\n = value\n":                     ":2: missing key name",
+		"[a]\nx = 1 \\\ncontinued\n[]\nb=1\n": ":4: empty section name",
+	} {
+		_, err := NewConfigData(IniProtocol, []byte(data))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parse %q: err = %v, want %q", data, err, want)
+		}
+	}
+}
+
+func TestIniInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "includetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"app.ini":       "[server]\nname = app\ninclude = conf.d/db.ini\nport = 8080\n",
+		"conf.d/db.ini": "[db]\nhost = localhost\n",
+		"loop.ini":      "include = loop.ini\n",
+	}
+	for name, data := range files {
+		name = filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(name), 0755)
+		if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	iniconf, err := NewConfig(IniProtocol, filepath.Join(dir, "app.ini"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host := iniconf.GetString("db.host"); host != "localhost" {
+		t.Errorf("db.host = %s", host)
+	}
+	// keys after the include stay in the including section
+	if port := iniconf.GetInt("server.port"); port != 8080 {
+		t.Errorf("server.port = %d", port)
+	}
+	if files := iniconf.(Reloadable).Files(); len(files) != 2 {
+		t.Errorf("files = %q", files)
+	}
+
+	if _, err := NewConfig(IniProtocol, filepath.Join(dir, "loop.ini")); err == nil || !strings.Contains(err.Error(), "include loop") {
+		t.Errorf("loop.ini: err = %v", err)
+	}
+}
+
+func TestNewConfigReader(t *testing.T) {
+	iniconf, err := NewConfigReader(IniProtocol, strings.NewReader(iniSyntaxData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host := iniconf.GetString("prod.host"); host != "db.example.com" {
+		t.Errorf("prod.host = %s", host)
+	}
+	if _, _, err := iniconf.(Reloadable).Reload(); err == nil {
+		t.Error("Reload of reader data succeeded")
+	}
+
+	_, err = NewConfigReader(IniProtocol, strings.NewReader("[a\n"))
+	if err == nil || err.Error() != `config: <data>:1: bad section header "[a"` {
+		t.Errorf("err = %v", err)
+	}
+	if _, err := NewConfigReader("xml", strings.NewReader("")); err == nil {
+		t.Error("unknown adapter accepted")
+	}
+}
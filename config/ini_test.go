@@ -26,28 +26,28 @@ func TestIniFile(t *testing.T) {
 		t.Errorf("server.platformdef = %q", platform)
 	}
 
-	if b, _ := iniconf.GetBool("server.enablessl"); !b {
+	if b := iniconf.GetBool("server.enablessl"); !b {
 		t.Errorf("server.enablessl = %t", b)
 	}
 
-	if b, _ := iniconf.GetBool("server.enablessldef", true); !b {
+	if b := iniconf.GetBool("server.enablessldef", true); !b {
 		t.Errorf("server.enablessldef = %t", b)
 	}
 
-	if port, _ := iniconf.GetInt("server.port"); port != 8080 {
+	if port := iniconf.GetInt("server.port"); port != 8080 {
 		t.Errorf("server.port = %d", port)
 	}
 
-	if port, _ := iniconf.GetInt("server.portdef", 80); port != 80 {
-		t.Errorf("server.port = %d", port)
+	if port := iniconf.GetInt("server.portdef", 80); port != 80 {
+		t.Errorf("server.portdef = %d", port)
 	}
 
-	if pi, _ := iniconf.GetFloat("server.PI"); pi != 3.14 {
-		t.Errorf("server.port = %d", pi)
+	if pi := iniconf.GetFloat("server.PI"); pi != 3.14 {
+		t.Errorf("server.PI = %v", pi)
 	}
 
-	if pi, _ := iniconf.GetFloat("server.PIdef", 3.141); pi != 3.141 {
-		t.Errorf("server.port = %d", pi)
+	if pi := iniconf.GetFloat("server.PIdef", 3.141); pi != 3.141 {
+		t.Errorf("server.PIdef = %v", pi)
 	}
 }
 
@@ -80,27 +80,27 @@ func TestIniData(t *testing.T) {
 		t.Errorf("server.platformdef = %q", platform)
 	}
 
-	if b, _ := iniconf.GetBool("server.enablessl"); !b {
+	if b := iniconf.GetBool("server.enablessl"); !b {
 		t.Errorf("server.enablessl = %t", b)
 	}
 
-	if b, _ := iniconf.GetBool("server.enablessldef", true); !b {
+	if b := iniconf.GetBool("server.enablessldef", true); !b {
 		t.Errorf("server.enablessldef = %t", b)
 	}
 
-	if port, _ := iniconf.GetInt("server.port"); port != 8080 {
+	if port := iniconf.GetInt("server.port"); port != 8080 {
 		t.Errorf("server.port = %d", port)
 	}
 
-	if port, _ := iniconf.GetInt("server.portdef", 80); port != 80 {
-		t.Errorf("server.port = %d", port)
+	if port := iniconf.GetInt("server.portdef", 80); port != 80 {
+		t.Errorf("server.portdef = %d", port)
 	}
 
-	if pi, _ := iniconf.GetFloat("server.PI"); pi != 3.14 {
-		t.Errorf("server.port = %d", pi)
+	if pi := iniconf.GetFloat("server.PI"); pi != 3.14 {
+		t.Errorf("server.PI = %v", pi)
 	}
 
-	if pi, _ := iniconf.GetFloat("server.PIdef", 3.141); pi != 3.141 {
-		t.Errorf("server.port = %d", pi)
+	if pi := iniconf.GetFloat("server.PIdef", 3.141); pi != 3.141 {
+		t.Errorf("server.PIdef = %v", pi)
 	}
 }
@@ -0,0 +1,313 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// iniLine is one logical line of the top file; continued lines are kept
+// together in raw.
+type iniLine struct {
+	raw     string
+	section string // section the line is in
+	key     string // set on key lines
+	header  bool   // set on the section header
+}
+
+func (c *IniConfigurer) addSection(section string) {
+	if _, ok := c.data[section]; !ok {
+		c.data[section] = make(map[string]string)
+		c.sections = append(c.sections, section)
+	}
+}
+
+func (c *IniConfigurer) setValue(section, key, val string) {
+	c.addSection(section)
+	if _, ok := c.data[section][key]; !ok {
+		c.keys[section] = append(c.keys[section], key)
+	}
+	c.data[section][key] = val
+}
+
+// splitKey finds the section and key of section_key the way Lookup reads
+// them. New keys go to the longest existing section, or are split at the
+// last dot.
+func (c *IniConfigurer) splitKey(section_key string) (section, key string) {
+	section_key = strings.ToLower(section_key)
+	for i := strings.LastIndex(section_key, "."); i >= 0; i = strings.LastIndex(section_key[:i], ".") {
+		if v, ok := c.data[section_key[:i]]; ok {
+			if _, ok := v[section_key[i+1:]]; ok {
+				return section_key[:i], section_key[i+1:]
+			}
+		}
+	}
+	if _, ok := c.data[""][section_key]; ok {
+		return "", section_key
+	}
+	for i := strings.LastIndex(section_key, "."); i >= 0; i = strings.LastIndex(section_key[:i], ".") {
+		if _, ok := c.data[section_key[:i]]; ok {
+			return section_key[:i], section_key[i+1:]
+		}
+	}
+	if i := strings.LastIndex(section_key, "."); i >= 0 {
+		return section_key[:i], section_key[i+1:]
+	}
+	return "", section_key
+}
+
+// Set sets section.key to val. An existing line keeps its layout and inline
+// comment, a new key is added after the last key of its section. An
+// ENC(...) val is saved as is and read decrypted.
+func (c *IniConfigurer) Set(section_key, val string) error {
+	plain, err := decryptSecret(val)
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	section, key := c.splitKey(section_key)
+	if key == "" {
+		return fmt.Errorf("config: Set empty key %q", section_key)
+	}
+	c.setValue(section, key, plain)
+
+	if ln := c.findLine(section, key); ln != nil {
+		prefix, suffix := splitKeyLine(ln.raw)
+		ln.raw = prefix + formatIniValue(val) + suffix
+		return nil
+	}
+
+	ln := &iniLine{raw: key + " = " + formatIniValue(val), section: section, key: key}
+	at := -1
+	for i, l := range c.lines {
+		if l.section == section && (l.key != "" || l.header) {
+			at = i
+		}
+	}
+	if at < 0 && section == "" {
+		// before the first section, after any leading comments; at stays
+		// -1 when the file starts with a header, inserting at the top
+		for at+1 < len(c.lines) && !c.lines[at+1].header {
+			at++
+		}
+	}
+	if at < 0 && section != "" {
+		if len(c.lines) > 0 && strings.TrimSpace(c.lines[len(c.lines)-1].raw) != "" {
+			c.lines = append(c.lines, &iniLine{section: section})
+		}
+		c.lines = append(c.lines, &iniLine{raw: "[" + section + "]", section: section, header: true}, ln)
+		return nil
+	}
+	c.lines = append(c.lines, nil)
+	copy(c.lines[at+2:], c.lines[at+1:])
+	c.lines[at+1] = ln
+	return nil
+}
+
+// Delete removes section.key, or a whole section when no such key exists,
+// together with the comment lines directly above it.
+func (c *IniConfigurer) Delete(section_key string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	section, key := c.splitKey(section_key)
+	if _, ok := c.data[section][key]; ok {
+		delete(c.data[section], key)
+		delete(c.keyComment, joinKey(section, key))
+		c.keys[section] = removeString(c.keys[section], key)
+		c.removeLines(func(l *iniLine) bool { return l.section == section && l.key == key }, false)
+		return nil
+	}
+
+	section = strings.ToLower(section_key)
+	if _, ok := c.data[section]; ok && section != "" {
+		// only its own keys: "server." also prefixes the keys of [server.tls]
+		for _, k := range c.keys[section] {
+			delete(c.keyComment, joinKey(section, k))
+		}
+		delete(c.data, section)
+		delete(c.keys, section)
+		delete(c.sectionComment, section)
+		c.sections = removeString(c.sections, section)
+
+		// comments at the end of the section belong to the next header
+		keep := make(map[*iniLine]bool)
+		for i, ln := range c.lines {
+			if ln.section != section || i+1 == len(c.lines) || c.lines[i+1].section == section {
+				continue
+			}
+			for j := i; j >= 0 && c.lines[j].section == section && c.lines[j].key == "" &&
+				!c.lines[j].header; j-- {
+				keep[c.lines[j]] = true
+			}
+		}
+		c.removeLines(func(l *iniLine) bool { return l.section == section && !keep[l] }, true)
+		return nil
+	}
+	return fmt.Errorf("config: Delete unknown key %q", section_key)
+}
+
+// Sections returns the section names in file order; "" holds the keys
+// before the first section.
+func (c *IniConfigurer) Sections() []string {
+	c.RLock()
+	defer c.RUnlock()
+	return append([]string(nil), c.sections...)
+}
+
+// Keys returns the key names of section in file order.
+func (c *IniConfigurer) Keys(section string) []string {
+	c.RLock()
+	defer c.RUnlock()
+	return append([]string(nil), c.keys[strings.ToLower(section)]...)
+}
+
+// Save writes the configuration back to the file it was read from.
+func (c *IniConfigurer) Save() error {
+	if c.filename == "" {
+		return errors.New("config: Save of data not read from a file, use SaveTo")
+	}
+	return c.SaveTo(c.filename)
+}
+
+// SaveTo writes the configuration to name, keeping the comments, order and
+// formatting of the original file. Keys that came from included files are
+// not written unless they were Set. The file is replaced atomically.
+func (c *IniConfigurer) SaveTo(name string) error {
+	c.RLock()
+	var buf bytes.Buffer
+	for _, ln := range c.lines {
+		buf.WriteString(ln.raw)
+		buf.WriteString(iniLineBreak)
+	}
+	c.RUnlock()
+
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(name); err == nil {
+		mode = fi.Mode()
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (c *IniConfigurer) findLine(section, key string) *iniLine {
+	for i := len(c.lines) - 1; i >= 0; i-- {
+		if ln := c.lines[i]; ln.section == section && ln.key == key {
+			return ln
+		}
+	}
+	return nil
+}
+
+// removeLines drops the lines matching del and the comments right above
+// them; blank also drops the blank lines separating them.
+func (c *IniConfigurer) removeLines(del func(*iniLine) bool, blank bool) {
+	lines := c.lines[:0]
+	for _, ln := range c.lines {
+		if del(ln) {
+			for len(lines) > 0 {
+				raw := lines[len(lines)-1].raw
+				if !isCommentLine(raw) && (!blank || strings.TrimSpace(raw) != "") {
+					break
+				}
+				lines = lines[:len(lines)-1]
+			}
+			continue
+		}
+		lines = append(lines, ln)
+	}
+	c.lines = lines
+}
+
+func isCommentLine(raw string) bool {
+	raw = strings.TrimSpace(raw)
+	return strings.HasPrefix(raw, string(iniComment)) || strings.HasPrefix(raw, string(iniHashComment))
+}
+
+// splitKeyLine returns the text of a key line before and after its value,
+// i.e. "key = " and an inline comment such as "  ; note".
+func splitKeyLine(raw string) (prefix, suffix string) {
+	if strings.Contains(raw, iniLineBreak) {
+		raw = raw[:strings.Index(raw, iniLineBreak)] // continued values are rewritten on one line
+	}
+	eq := strings.Index(raw, string(iniEqual))
+	if ci := indexComment([]byte(raw)); eq < 0 || (ci >= 0 && ci < eq) {
+		// bare key
+		if ci >= 0 {
+			return strings.TrimRight(raw[:ci], " \t") + " = ", " " + raw[ci:]
+		}
+		return strings.TrimRight(raw, " \t") + " = ", ""
+	}
+	i := eq + 1
+	for i < len(raw) && (raw[i] == ' ' || raw[i] == '\t') {
+		i++
+	}
+	prefix, rest := raw[:i], raw[i:]
+
+	end := -1
+	if len(rest) > 0 && (rest[0] == '"' || rest[0] == '\'') {
+		for j := 1; j < len(rest); j++ {
+			if rest[0] == '"' && rest[j] == '\\' {
+				j++
+				continue
+			}
+			if rest[j] == rest[0] {
+				end = indexComment([]byte(rest[j+1:]))
+				if end >= 0 {
+					end += j + 1
+				}
+				break
+			}
+		}
+	} else {
+		end = indexComment([]byte(rest))
+	}
+	if end < 0 {
+		return prefix, ""
+	}
+	value := rest[:end]
+	trimmed := strings.TrimRight(value, " \t")
+	return prefix, value[len(trimmed):] + rest[end:]
+}
+
+// formatIniValue quotes val when it would not read back unchanged.
+func formatIniValue(val string) string {
+	if val == "" || (strings.TrimSpace(val) == val &&
+		!strings.ContainsAny(val, ";#\"'\\\n\r\t")) {
+		return val
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(val) + `"`
+}
+
+func removeString(list []string, s string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
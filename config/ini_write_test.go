@@ -0,0 +1,187 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var iniWriteData = `; global settings
+appname = litego
+
+; server settings
+[server]
+; listen port
+port   =   8080   ; inline
+name = "test server"
+hosts = a, \
+        b
+debug
+
+# database
+[db]
+host = localhost
+`
+
+var iniWriteWant = `; global settings
+appname = litego
+env = prod
+
+; server settings
+[server]
+; listen port
+port   =   9090   ; inline
+name = "a; b"
+hosts = c
+debug = false
+timeout = 3s
+
+# database
+[db]
+host = localhost
+
+[cache]
+size = 10
+`
+
+func TestIniSaveTo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "savetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "app.ini")
+	if err := ioutil.WriteFile(name, []byte(iniWriteData), 0600); err != nil {
+		t.Fatal(err)
+	}
+	iniconf, err := NewConfig(IniProtocol, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := iniconf.(*IniConfigurer)
+
+	if s := c.Sections(); !reflect.DeepEqual(s, []string{"", "server", "db"}) {
+		t.Errorf("Sections() = %q", s)
+	}
+	if k := c.Keys("server"); !reflect.DeepEqual(k, []string{"port", "name", "hosts", "debug"}) {
+		t.Errorf("Keys(server) = %q", k)
+	}
+
+	for key, val := range map[string]string{
+		"server.port":    "9090",
+		"server.name":    "a; b",
+		"server.hosts":   "c",
+		"server.debug":   "false",
+		"server.timeout": "3s",
+		"env":            "prod",
+		"cache.size":     "10",
+	} {
+		if err := c.Set(key, val); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.SaveTo(name); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != iniWriteWant {
+		t.Errorf("saved:\n%s\nwant:\n%s", data, iniWriteWant)
+	}
+	if fi, _ := os.Stat(name); fi.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v", fi.Mode())
+	}
+
+	saved, err := NewConfig(IniProtocol, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := saved.GetString("server.name"); v != "a; b" {
+		t.Errorf("server.name = %q", v)
+	}
+
+	if err := c.Delete("server.port"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete("server"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete("server.nothing"); err == nil {
+		t.Error("Delete of unknown key succeeded")
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = ioutil.ReadFile(name)
+	want := "; global settings\nappname = litego\nenv = prod\n\n# database\n[db]\nhost = localhost\n\n[cache]\nsize = 10\n"
+	if string(data) != want {
+		t.Errorf("saved:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestIniSetDefaultSectionFirstHeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "savetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "app.ini")
+	if err := ioutil.WriteFile(name, []byte("[server]\nport = 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewConfig(IniProtocol, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.(*IniConfigurer).Set("env", "prod"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.(*IniConfigurer).SaveTo(name); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "env = prod\n[server]\nport = 1\n"; string(data) != want {
+		t.Errorf("saved %q, want %q", data, want)
+	}
+	saved, err := NewConfig(IniProtocol, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := saved.GetString("env"); v != "prod" {
+		t.Errorf("env = %q", v)
+	}
+	if v := saved.GetString("server.port"); v != "1" {
+		t.Errorf("server.port = %q", v)
+	}
+}
+
+func TestIniDeleteSectionKeepsSubSection(t *testing.T) {
+	iniconf, err := NewConfigData(IniProtocol, []byte("[server]\n; listen port\nport = 1\n[server.tls]\n; pem file\ncert = a.pem\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := iniconf.(*IniConfigurer)
+	if err := c.Delete("server"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.keyComment["server.port"]; ok {
+		t.Error("kept the key comment of the deleted section")
+	}
+	if comment := c.keyComment["server.tls.cert"]; comment != "pem file\n" {
+		t.Errorf("server.tls.cert comment = %q", comment)
+	}
+	if v := c.GetString("server.tls.cert"); v != "a.pem" {
+		t.Errorf("server.tls.cert = %q", v)
+	}
+}
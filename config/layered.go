@@ -0,0 +1,232 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Layer is one source of values for a LayeredConfigurer.
+type Layer interface {
+	Name() string
+	Lookup(key string) (string, bool)
+}
+
+type flagLayer struct {
+	fs *flag.FlagSet
+}
+
+// FlagLayer resolves keys from the flags of fs that were set on the command
+// line; flags are named after the key, e.g. -server.port=9090. A nil fs
+// means flag.CommandLine. fs must be parsed before values are looked up.
+func FlagLayer(fs *flag.FlagSet) Layer {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	return &flagLayer{fs}
+}
+
+func (l *flagLayer) Name() string {
+	return "flag"
+}
+
+func (l *flagLayer) Lookup(key string) (val string, ok bool) {
+	key = strings.ToLower(key)
+	l.fs.Visit(func(f *flag.Flag) {
+		if strings.ToLower(f.Name) == key {
+			val, ok = f.Value.String(), true
+		}
+	})
+	return
+}
+
+type envLayer struct {
+	prefix string
+}
+
+// EnvLayer resolves keys from environment variables: with prefix "APP" the
+// key server.port is read from APP_SERVER_PORT.
+func EnvLayer(prefix string) Layer {
+	return &envLayer{prefix}
+}
+
+func (l *envLayer) Name() string {
+	return "env"
+}
+
+func (l *envLayer) Lookup(key string) (string, bool) {
+	return os.LookupEnv(EnvName(l.prefix, key))
+}
+
+// EnvName returns the environment variable EnvLayer(prefix) reads key from.
+func EnvName(prefix, key string) string {
+	name := strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+	if prefix == "" {
+		return name
+	}
+	return strings.ToUpper(prefix) + "_" + name
+}
+
+type configLayer struct {
+	name string
+	cfg  Configurer
+}
+
+// ConfigLayer resolves keys from a parsed configuration such as the one
+// returned by NewConfig.
+func ConfigLayer(name string, c Configurer) Layer {
+	return &configLayer{name, c}
+}
+
+func (l *configLayer) Name() string {
+	return l.name
+}
+
+func (l *configLayer) Lookup(key string) (string, bool) {
+	if lc, ok := l.cfg.(interface {
+		Lookup(string) (string, bool)
+	}); ok {
+		return lc.Lookup(key)
+	}
+	val := l.cfg.GetString(key)
+	return val, val != ""
+}
+
+// LayeredConfigurer resolves every key from the first layer that has it,
+// so NewLayeredConfig(FlagLayer(nil), EnvLayer("APP"), ConfigLayer("ini", c))
+// lets flags override the environment, which overrides the file. Values
+// have ${VAR} and ${VAR:-default} expanded from the environment.
+type LayeredConfigurer struct {
+	layers []Layer
+}
+
+func NewLayeredConfig(layers ...Layer) *LayeredConfigurer {
+	return &LayeredConfigurer{layers}
+}
+
+// Lookup returns the expanded value of key and whether any layer has it.
+func (c *LayeredConfigurer) Lookup(key string) (string, bool) {
+	for _, l := range c.layers {
+		if val, ok := l.Lookup(key); ok {
+			return Expand(val), true
+		}
+	}
+	return "", false
+}
+
+// Source returns the name of the layer that supplies key, or "" if none.
+func (c *LayeredConfigurer) Source(key string) string {
+	for _, l := range c.layers {
+		if _, ok := l.Lookup(key); ok {
+			return l.Name()
+		}
+	}
+	return ""
+}
+
+func (c *LayeredConfigurer) GetBool(key string, v ...bool) bool {
+	if val, err := strconv.ParseBool(c.getdata(key)); err == nil {
+		return val
+	}
+	if len(v) > 0 {
+		return v[0]
+	}
+	return false
+}
+
+func (c *LayeredConfigurer) GetFloat(key string, v ...float64) float64 {
+	if val, err := strconv.ParseFloat(c.getdata(key), 64); err == nil {
+		return val
+	}
+	if len(v) > 0 {
+		return v[0]
+	}
+	return 0
+}
+
+func (c *LayeredConfigurer) GetInt(key string, v ...int) int {
+	if val, err := strconv.Atoi(c.getdata(key)); err == nil {
+		return val
+	}
+	if len(v) > 0 {
+		return v[0]
+	}
+	return 0
+}
+
+func (c *LayeredConfigurer) GetInt64(key string, v ...int64) int64 {
+	if val, err := strconv.ParseInt(c.getdata(key), 10, 64); err == nil {
+		return val
+	}
+	if len(v) > 0 {
+		return v[0]
+	}
+	return 0
+}
+
+func (c *LayeredConfigurer) GetString(key string, v ...string) string {
+	if val := c.getdata(key); val != "" {
+		return val
+	}
+	if len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c *LayeredConfigurer) GetStrings(key string, v ...string) []string {
+	var val []string
+	if val = strings.Split(c.GetString(key), ","); len(val) == 1 && val[0] == "" {
+		if len(v) > 0 {
+			val = strings.Split(v[0], ",")
+		}
+	}
+	return val
+}
+
+func (c *LayeredConfigurer) getdata(key string) string {
+	val, _ := c.Lookup(key)
+	return val
+}
+
+// Expand replaces ${VAR} and ${VAR:-default} in s with environment values;
+// the default is used when VAR is unset or empty. $${ is a literal ${.
+func Expand(s string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+
+	var buf strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			break
+		}
+		if i > 0 && s[i-1] == '$' {
+			buf.WriteString(s[:i-1])
+			buf.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			break
+		}
+		buf.WriteString(s[:i])
+
+		expr := s[i+2 : i+end]
+		name, def := expr, ""
+		if j := strings.Index(expr, ":-"); j >= 0 {
+			name, def = expr[:j], expr[j+2:]
+		}
+		if val := os.Getenv(name); val != "" {
+			buf.WriteString(val)
+		} else {
+			buf.WriteString(def)
+		}
+		s = s[i+end+1:]
+	}
+	buf.WriteString(s)
+	return buf.String()
+}
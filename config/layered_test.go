@@ -0,0 +1,79 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"testing"
+)
+
+func TestLayeredConfig(t *testing.T) {
+	iniconf, err := NewConfigData(IniProtocol, []byte(`[server]
+name = testserver
+port = 8080
+host = 127.0.0.1
+logdir = ${LAYERED_TEST_HOME:-/var/log}/app
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("server.name", "", "")
+	fs.String("server.host", "", "")
+	if err := fs.Parse([]string{"-server.name=flagserver"}); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("APP_SERVER_PORT", "9090")
+	os.Setenv("APP_SERVER_NAME", "envserver")
+	defer os.Unsetenv("APP_SERVER_PORT")
+	defer os.Unsetenv("APP_SERVER_NAME")
+
+	c := NewLayeredConfig(FlagLayer(fs), EnvLayer("app"), ConfigLayer("ini", iniconf))
+
+	if name := c.GetString("server.name"); name != "flagserver" {
+		t.Errorf("server.name = %s", name)
+	}
+	if port := c.GetInt("server.port"); port != 9090 {
+		t.Errorf("server.port = %d", port)
+	}
+	if host := c.GetString("server.host"); host != "127.0.0.1" {
+		t.Errorf("server.host = %s", host)
+	}
+	if dir := c.GetString("server.logdir"); dir != "/var/log/app" {
+		t.Errorf("server.logdir = %s", dir)
+	}
+	if b := c.GetBool("server.enablessl", true); !b {
+		t.Errorf("server.enablessl = %t", b)
+	}
+
+	for key, want := range map[string]string{
+		"server.name":    "flag",
+		"server.port":    "env",
+		"server.host":    "ini",
+		"server.missing": "",
+	} {
+		if src := c.Source(key); src != want {
+			t.Errorf("Source(%s) = %q, want %q", key, src, want)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	os.Setenv("EXPAND_TEST", "x")
+	defer os.Unsetenv("EXPAND_TEST")
+
+	for in, want := range map[string]string{
+		"plain":                    "plain",
+		"${EXPAND_TEST}/a":         "x/a",
+		"${EXPAND_UNSET:-def}":     "def",
+		"${EXPAND_TEST:-def}":      "x",
+		"$${EXPAND_TEST}":          "${EXPAND_TEST}",
+		"a${EXPAND_TEST}b${NONE}c": "axbc",
+		"${broken":                 "${broken",
+	} {
+		if got := Expand(in); got != want {
+			t.Errorf("Expand(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types of a SchemaKey.
+const (
+	TypeString   = "string"
+	TypeStrings  = "strings" // comma separated list
+	TypeInt      = "int"
+	TypeFloat    = "float"
+	TypeBool     = "bool"
+	TypeDuration = "duration"
+)
+
+var (
+	ErrUnknownKey   = errors.New("unknown key")
+	ErrInvalidValue = errors.New("invalid value")
+)
+
+// SchemaKey describes one section.key. Min and Max are written like values
+// of the key's type ("1", "500ms"), or are lengths for strings; empty means
+// unbounded. Enum lists the allowed values, for TypeStrings every item must
+// be one of them.
+type SchemaKey struct {
+	Key      string
+	Type     string
+	Required bool
+	Default  string
+	Min      string
+	Max      string
+	Enum     []string
+	Comment  string
+}
+
+// Schema lists the keys a configuration may have.
+type Schema struct {
+	Keys []SchemaKey
+}
+
+func NewSchema(keys ...SchemaKey) *Schema {
+	return &Schema{Keys: keys}
+}
+
+// LoadSchemaFile reads a schema written as ini, one section per key:
+//
+//	; listen port
+//	[server.port]
+//	type = int
+//	min = 1
+//	max = 65535
+//	required
+//
+// The attributes are type, required, default, min, max, enum and comment;
+// without a comment attribute the section comment is used.
+func LoadSchemaFile(name string) (*Schema, error) {
+	cfg, err := NewConfig(IniProtocol, name)
+	if err != nil {
+		return nil, err
+	}
+	return LoadSchema(cfg.(*IniConfigurer))
+}
+
+// LoadSchema reads a schema from a parsed configuration, see LoadSchemaFile.
+func LoadSchema(c *IniConfigurer) (*Schema, error) {
+	s := &Schema{}
+	for _, section := range c.Sections() {
+		if section == "" {
+			continue
+		}
+		key := SchemaKey{Key: section, Type: TypeString}
+		for _, attr := range c.Keys(section) {
+			val, _ := c.Lookup(section + "." + attr)
+			switch attr {
+			case "type":
+				key.Type = val
+			case "required":
+				b, err := strconv.ParseBool(val)
+				if err != nil {
+					return nil, fmt.Errorf("config: schema %s: required: %v", section, err)
+				}
+				key.Required = b
+			case "default":
+				key.Default = val
+			case "min":
+				key.Min = val
+			case "max":
+				key.Max = val
+			case "enum":
+				for _, e := range strings.Split(val, ",") {
+					key.Enum = append(key.Enum, strings.TrimSpace(e))
+				}
+			case "comment":
+				key.Comment = val
+			default:
+				return nil, fmt.Errorf("config: schema %s: unknown attribute %q", section, attr)
+			}
+		}
+		if key.Comment == "" {
+			c.RLock()
+			key.Comment = strings.TrimSpace(c.sectionComment[section])
+			c.RUnlock()
+		}
+		s.Keys = append(s.Keys, key)
+	}
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// check verifies the schema itself: known types and parsable bounds.
+func (s *Schema) check() error {
+	for _, k := range s.Keys {
+		if _, err := parseTyped(k.Type, ""); err == errBadType {
+			return fmt.Errorf("config: schema %s: unknown type %q", k.Key, k.Type)
+		}
+		for _, bound := range []string{k.Min, k.Max} {
+			if bound == "" {
+				continue
+			}
+			if _, err := parseBound(k.Type, bound); err != nil {
+				return fmt.Errorf("config: schema %s: bound %q: %v", k.Key, bound, err)
+			}
+		}
+		if k.Default != "" {
+			if err := k.validate(k.Default); err != nil {
+				return fmt.Errorf("config: schema %s: default: %v", k.Key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidationErrors collects every problem Validate found.
+type ValidationErrors []*FieldError
+
+func (e ValidationErrors) Error() string {
+	return UnmarshalErrors(e).Error()
+}
+
+// Validate checks c against the schema: required keys must be set and not
+// empty, as WriteSample leaves them, and every value must have the right
+// type, range and enum. When c can list its keys, as IniConfigurer does,
+// keys not in the schema are reported as well.
+func (s *Schema) Validate(c Configurer) error {
+	var errs ValidationErrors
+	known := make(map[string]bool)
+	for _, k := range s.Keys {
+		name := strings.ToLower(k.Key)
+		known[name] = true
+
+		val, ok := lookupKey(c, name)
+		if !ok || (val == "" && k.Required) {
+			if k.Required {
+				errs = append(errs, &FieldError{Key: name, Err: ErrMissingKey})
+			}
+			continue
+		}
+		if err := k.validate(val); err != nil {
+			errs = append(errs, &FieldError{Key: name, Err: err})
+		}
+	}
+
+	if lister, ok := c.(interface {
+		Sections() []string
+		Keys(string) []string
+	}); ok {
+		for _, section := range lister.Sections() {
+			for _, key := range lister.Keys(section) {
+				if name := joinKey(section, key); !known[name] {
+					errs = append(errs, &FieldError{Key: name, Err: ErrUnknownKey})
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func lookupKey(c Configurer, key string) (string, bool) {
+	if lc, ok := c.(interface {
+		Lookup(string) (string, bool)
+	}); ok {
+		return lc.Lookup(key)
+	}
+	val := c.GetString(key)
+	return val, val != ""
+}
+
+func (k *SchemaKey) validate(val string) error {
+	items := []string{val}
+	if k.Type == TypeStrings {
+		items = strings.Split(val, ",")
+	}
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		v, err := parseTyped(k.Type, item)
+		if err != nil {
+			return fmt.Errorf("%w: %q is not a %s", ErrInvalidValue, item, k.Type)
+		}
+		if len(k.Enum) > 0 && !containsFold(k.Enum, item) {
+			return fmt.Errorf("%w: %q is not one of %s", ErrInvalidValue, item, strings.Join(k.Enum, ", "))
+		}
+		if k.Min != "" {
+			if min, _ := parseBound(k.Type, k.Min); v < min {
+				return fmt.Errorf("%w: %s is below %s", ErrInvalidValue, item, k.Min)
+			}
+		}
+		if k.Max != "" {
+			if max, _ := parseBound(k.Type, k.Max); v > max {
+				return fmt.Errorf("%w: %s is above %s", ErrInvalidValue, item, k.Max)
+			}
+		}
+	}
+	return nil
+}
+
+var errBadType = errors.New("unknown type")
+
+// parseTyped parses val as typ and returns a number to compare bounds with.
+func parseTyped(typ, val string) (float64, error) {
+	switch typ {
+	case TypeString, TypeStrings, "":
+		return float64(len(val)), nil
+	case TypeInt:
+		n, err := strconv.ParseInt(val, 10, 64)
+		return float64(n), err
+	case TypeFloat:
+		return strconv.ParseFloat(val, 64)
+	case TypeBool:
+		_, err := strconv.ParseBool(val)
+		return 0, err
+	case TypeDuration:
+		d, err := time.ParseDuration(val)
+		return float64(d), err
+	}
+	return 0, errBadType
+}
+
+// parseBound parses a Min or Max; for strings they are lengths.
+func parseBound(typ, bound string) (float64, error) {
+	if typ == TypeString || typ == TypeStrings || typ == "" {
+		return strconv.ParseFloat(bound, 64)
+	}
+	return parseTyped(typ, bound)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteSample writes a commented sample ini for the schema. Each key gets
+// its comment and constraints as ; lines right above it, which IniConfigurer
+// reads back as the key comment. Optional keys without a default are
+// written commented out.
+func (s *Schema) WriteSample(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	var order []string
+	bySection := make(map[string][]SchemaKey)
+	for _, k := range s.Keys {
+		section, key := "", strings.ToLower(k.Key)
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			section, key = key[:i], key[i+1:]
+		}
+		if _, ok := bySection[section]; !ok {
+			order = append(order, section)
+		}
+		k.Key = key
+		bySection[section] = append(bySection[section], k)
+	}
+
+	// keys of the default section must come before any header
+	for i, section := range order {
+		if section == "" && i > 0 {
+			copy(order[1:i+1], order[:i])
+			order[0] = ""
+		}
+	}
+
+	for i, section := range order {
+		if i > 0 {
+			bw.WriteString(iniLineBreak)
+		}
+		if section != "" {
+			fmt.Fprintf(bw, "[%s]%s", section, iniLineBreak)
+		}
+		for j, k := range bySection[section] {
+			if j > 0 {
+				bw.WriteString(iniLineBreak)
+			}
+			if k.Comment != "" {
+				for _, line := range strings.Split(k.Comment, iniLineBreak) {
+					fmt.Fprintf(bw, "; %s%s", line, iniLineBreak)
+				}
+			}
+			fmt.Fprintf(bw, "; %s%s", k.describe(), iniLineBreak)
+			switch {
+			case k.Default != "":
+				fmt.Fprintf(bw, "%s = %s%s", k.Key, formatIniValue(k.Default), iniLineBreak)
+			case k.Required:
+				fmt.Fprintf(bw, "%s =%s", k.Key, iniLineBreak)
+			default:
+				fmt.Fprintf(bw, ";%s =%s", k.Key, iniLineBreak)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// describe returns e.g. "int, 1..65535, required".
+func (k *SchemaKey) describe() string {
+	typ := k.Type
+	if typ == "" {
+		typ = TypeString
+	}
+	parts := []string{typ}
+	if k.Min != "" || k.Max != "" {
+		parts = append(parts, k.Min+".."+k.Max)
+	}
+	if len(k.Enum) > 0 {
+		parts = append(parts, "one of "+strings.Join(k.Enum, "|"))
+	}
+	if k.Required {
+		parts = append(parts, "required")
+	}
+	return strings.Join(parts, ", ")
+}
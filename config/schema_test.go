@@ -0,0 +1,135 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var schemaTestFile = `; application name
+[appname]
+required
+
+; listen port
+[server.port]
+type = int
+min = 1
+max = 65535
+default = 8080
+
+[server.mode]
+enum = debug, release
+required
+
+[server.timeout]
+type = duration
+max = 1m
+
+[server.platform]
+type = strings
+enum = ios, android
+`
+
+func TestSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schematest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "schema.ini")
+	if err := ioutil.WriteFile(name, []byte(schemaTestFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := LoadSchemaFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	good, err := NewConfigData(IniProtocol, []byte("appname = litego\n[server]\nport = 80\nmode = release\nplatform = ios,android\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Validate(good); err != nil {
+		t.Errorf("Validate(good) = %v", err)
+	}
+
+	bad, err := NewConfigData(IniProtocol, []byte("[server]\nport = 70000\nmode = test\ntimeout = 2m\nplatform = ios,wp\nprot = 80\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = schema.Validate(bad)
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Validate(bad) = %v", err)
+	}
+	want := []struct {
+		key string
+		err error
+	}{
+		{"appname", ErrMissingKey},
+		{"server.port", ErrInvalidValue},
+		{"server.mode", ErrInvalidValue},
+		{"server.timeout", ErrInvalidValue},
+		{"server.platform", ErrInvalidValue},
+		{"server.prot", ErrUnknownKey},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Validate(bad) = %v", errs)
+	}
+	for i, w := range want {
+		if errs[i].Key != w.key || !errors.Is(errs[i], w.err) {
+			t.Errorf("errs[%d] = %v, want %s: %v", i, errs[i], w.key, w.err)
+		}
+	}
+}
+
+func TestSchemaSample(t *testing.T) {
+	schema := NewSchema(
+		SchemaKey{Key: "server.name", Comment: "server name"},
+		SchemaKey{Key: "server.port", Type: TypeInt, Min: "1", Max: "65535", Default: "8080", Comment: "listen port"},
+		SchemaKey{Key: "appname", Required: true},
+	)
+	var buf bytes.Buffer
+	if err := schema.WriteSample(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `; string, required
+appname =
+
+[server]
+; server name
+; string
+;name =
+
+; listen port
+; int, 1..65535
+port = 8080
+`
+	if buf.String() != want {
+		t.Errorf("sample:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	sample, err := NewConfigData(IniProtocol, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := sample.(*IniConfigurer)
+	// the commented out name and its description stay off the next key
+	if comment := c.keyComment["server.port"]; comment != "listen port\nint, 1..65535\n" {
+		t.Errorf("server.port comment = %q", comment)
+	}
+
+	// a fresh sample still needs its required keys filled in
+	err = schema.Validate(c)
+	if errs, ok := err.(ValidationErrors); !ok || len(errs) != 1 || errs[0].Key != "appname" || !errors.Is(errs[0], ErrMissingKey) {
+		t.Errorf("Validate(sample) = %v", err)
+	}
+
+	if _, err := LoadSchema(c); err == nil {
+		t.Error("sample loaded as schema")
+	}
+}
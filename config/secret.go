@@ -0,0 +1,159 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Values written as ENC(...) are decrypted with AES-GCM while parsing. The
+// key is the one given to SetSecretKey, else the base64 key in
+// $LITEGO_CONFIG_KEY, else the key file named by $LITEGO_CONFIG_KEYFILE.
+const (
+	SecretKeyEnv     = "LITEGO_CONFIG_KEY"
+	SecretKeyFileEnv = "LITEGO_CONFIG_KEYFILE"
+
+	secretPrefix = "ENC("
+	secretSuffix = ")"
+)
+
+var ErrNoSecretKey = errors.New("config: no secret key, set " + SecretKeyEnv + " or " + SecretKeyFileEnv)
+
+var (
+	secretMu  sync.RWMutex
+	secretKey []byte
+)
+
+// SetSecretKey sets the key used for ENC(...) values; it must be 16, 24 or
+// 32 bytes long. A nil key falls back to the environment again.
+func SetSecretKey(key []byte) error {
+	if key != nil {
+		if _, err := aes.NewCipher(key); err != nil {
+			return fmt.Errorf("config: secret key: %v", err)
+		}
+	}
+	secretMu.Lock()
+	secretKey = key
+	secretMu.Unlock()
+	return nil
+}
+
+// SecretKey returns the key ENC(...) values are decrypted with.
+func SecretKey() ([]byte, error) {
+	secretMu.RLock()
+	key := secretKey
+	secretMu.RUnlock()
+	if key != nil {
+		return key, nil
+	}
+
+	if s := os.Getenv(SecretKeyEnv); s != "" {
+		return decodeSecretKey([]byte(s))
+	}
+	if name := os.Getenv(SecretKeyFileEnv); name != "" {
+		return LoadSecretKeyFile(name)
+	}
+	return nil, ErrNoSecretKey
+}
+
+// LoadSecretKeyFile reads a key file holding the base64 key, or the raw key.
+func LoadSecretKeyFile(name string) ([]byte, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSecretKey(data)
+}
+
+func decodeSecretKey(data []byte) ([]byte, error) {
+	key := data
+	if s := bytes.TrimSpace(data); len(s) > 0 {
+		if dec, err := base64.StdEncoding.DecodeString(string(s)); err == nil {
+			key = dec
+		}
+	}
+	if _, err := aes.NewCipher(key); err != nil {
+		return nil, fmt.Errorf("config: secret key: %v", err)
+	}
+	return key, nil
+}
+
+// GenerateSecretKey returns a new random 32 byte key.
+func GenerateSecretKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// IsEncrypted reports whether val is written as ENC(...).
+func IsEncrypted(val string) bool {
+	return strings.HasPrefix(val, secretPrefix) && strings.HasSuffix(val, secretSuffix)
+}
+
+// EncryptValue returns plaintext as ENC(base64(nonce + ciphertext)).
+func EncryptValue(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed) + secretSuffix, nil
+}
+
+// DecryptValue decrypts a value written by EncryptValue.
+func DecryptValue(key []byte, val string) (string, error) {
+	if !IsEncrypted(val) {
+		return "", errors.New("config: value is not ENC(...)")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(val[len(secretPrefix) : len(val)-len(secretSuffix)])
+	if err != nil {
+		return "", fmt.Errorf("config: encrypted value: %v", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("config: encrypted value too short")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("config: encrypted value: wrong key or corrupted data")
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("config: secret key: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptSecret returns val, decrypted if it is ENC(...).
+func decryptSecret(val string) (string, error) {
+	if !IsEncrypted(val) {
+		return val, nil
+	}
+	key, err := SecretKey()
+	if err != nil {
+		return "", err
+	}
+	return DecryptValue(key, val)
+}
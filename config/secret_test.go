@@ -0,0 +1,80 @@
+package config
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretValues(t *testing.T) {
+	key, err := GenerateSecretKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := EncryptValue(key, "s3cret;#pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(enc) {
+		t.Fatalf("EncryptValue = %s", enc)
+	}
+
+	data := "[db]\nuser = root\npassword = " + enc + "\n"
+
+	os.Setenv(SecretKeyEnv, base64.StdEncoding.EncodeToString(key))
+	iniconf, err := NewConfigData(IniProtocol, []byte(data))
+	os.Unsetenv(SecretKeyEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pass := iniconf.GetString("db.password"); pass != "s3cret;#pass" {
+		t.Errorf("db.password = %q", pass)
+	}
+
+	if _, err := NewConfigData(IniProtocol, []byte(data)); err == nil || !strings.Contains(err.Error(), ":3: key \"password\"") {
+		t.Errorf("parse without key: err = %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "secrettest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyfile := filepath.Join(dir, "app.key")
+	ioutil.WriteFile(keyfile, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600)
+
+	os.Setenv(SecretKeyFileEnv, keyfile)
+	defer os.Unsetenv(SecretKeyFileEnv)
+	iniconf, err = NewConfigData(IniProtocol, []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Set keeps the ciphertext in the file and the plaintext in memory
+	enc2, _ := EncryptValue(key, "newpass")
+	c := iniconf.(*IniConfigurer)
+	if err := c.Set("db.password", enc2); err != nil {
+		t.Fatal(err)
+	}
+	if pass := c.GetString("db.password"); pass != "newpass" {
+		t.Errorf("db.password = %q", pass)
+	}
+	name := filepath.Join(dir, "app.ini")
+	if err := c.SaveTo(name); err != nil {
+		t.Fatal(err)
+	}
+	saved, _ := ioutil.ReadFile(name)
+	if !strings.Contains(string(saved), "password = "+enc2+"\n") {
+		t.Errorf("saved:\n%s", saved)
+	}
+
+	other, _ := GenerateSecretKey()
+	SetSecretKey(other)
+	defer SetSecretKey(nil)
+	if _, err := NewConfigData(IniProtocol, []byte(data)); err == nil {
+		t.Error("parse with wrong key succeeded")
+	}
+}
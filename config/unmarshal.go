@@ -0,0 +1,223 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrMissingKey is reported for a required field whose key is not set.
+var ErrMissingKey = errors.New("missing required key")
+
+// FieldError describes one struct field Unmarshal could not fill, or one
+// key that failed schema validation.
+type FieldError struct {
+	Key   string // section.key looked up
+	Field string // Go field path, e.g. Server.TLS.Cert; empty from Validate
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("%s (%s): %v", e.Key, e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// UnmarshalErrors collects every field error of one Unmarshal call.
+type UnmarshalErrors []*FieldError
+
+func (e UnmarshalErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return "config: " + strings.Join(msgs, "; ")
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+var timeType = reflect.TypeOf(time.Time{})
+
+// Unmarshal fills the struct pointed to by dst from the keys of section.
+//
+// Fields are matched by the tag `config:"name,default=8080,required"`; an
+// empty name means the lower-cased field name and "-" skips the field.
+// Everything after default= up to the next option is the default, so
+// `config:"platform,default=ios,android"` works for slices. Nested structs
+// read the sub-section "section.name", slices are split on commas and
+// time.Duration uses time.ParseDuration. All missing or malformed fields are
+// returned together as UnmarshalErrors.
+func Unmarshal(c Configurer, section string, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: Unmarshal needs a non-nil struct pointer, got %T", dst)
+	}
+
+	var errs UnmarshalErrors
+	unmarshalStruct(c, section, "", rv.Elem(), &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+type fieldTag struct {
+	name     string
+	def      string
+	hasDef   bool
+	required bool
+}
+
+func parseFieldTag(field reflect.StructField) (tag fieldTag, skip bool) {
+	raw := field.Tag.Get("config")
+	if raw == "-" {
+		return tag, true
+	}
+
+	parts := strings.Split(raw, ",")
+	tag.name = strings.TrimSpace(parts[0])
+	if tag.name == "" {
+		tag.name = strings.ToLower(field.Name)
+	}
+
+	var indef bool
+	for _, opt := range parts[1:] {
+		switch {
+		case opt == "required":
+			tag.required = true
+			indef = false
+		case strings.HasPrefix(opt, "default="):
+			tag.def = strings.TrimPrefix(opt, "default=")
+			tag.hasDef = true
+			indef = true
+		case indef:
+			tag.def += "," + opt
+		}
+	}
+	return tag, false
+}
+
+func joinKey(section, name string) string {
+	if section == "" {
+		return name
+	}
+	return section + "." + name
+}
+
+func unmarshalStruct(c Configurer, section, path string, sv reflect.Value, errs *UnmarshalErrors) {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		tag, skip := parseFieldTag(field)
+		if skip {
+			continue
+		}
+
+		fv := sv.Field(i)
+		fpath := joinKey(path, field.Name)
+		key := joinKey(section, tag.name)
+
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr && ft.Elem().Kind() == reflect.Struct && ft.Elem() != timeType {
+			if fv.IsNil() {
+				fv.Set(reflect.New(ft.Elem()))
+			}
+			unmarshalStruct(c, key, fpath, fv.Elem(), errs)
+			continue
+		}
+		if ft.Kind() == reflect.Struct && ft != timeType {
+			if field.Anonymous && field.Tag.Get("config") == "" {
+				unmarshalStruct(c, section, fpath, fv, errs)
+			} else {
+				unmarshalStruct(c, key, fpath, fv, errs)
+			}
+			continue
+		}
+
+		val := c.GetString(key)
+		if val == "" {
+			if tag.required {
+				*errs = append(*errs, &FieldError{key, fpath, ErrMissingKey})
+				continue
+			}
+			if !tag.hasDef {
+				continue
+			}
+			val = tag.def
+		}
+
+		if err := setField(fv, val); err != nil {
+			*errs = append(*errs, &FieldError{key, fpath, err})
+		}
+	}
+}
+
+func setField(fv reflect.Value, val string) error {
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 0, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 0, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Ptr:
+		pv := reflect.New(fv.Type().Elem())
+		if err := setField(pv.Elem(), val); err != nil {
+			return err
+		}
+		fv.Set(pv)
+	case reflect.Slice:
+		items := strings.Split(val, ",")
+		sv := reflect.MakeSlice(fv.Type(), 0, len(items))
+		for i, item := range items {
+			ev := reflect.New(fv.Type().Elem()).Elem()
+			if err := setField(ev, strings.TrimSpace(item)); err != nil {
+				return fmt.Errorf("item %d: %v", i, err)
+			}
+			sv = reflect.Append(sv, ev)
+		}
+		fv.Set(sv)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var unmarshalTestData = `[server]
+name = testserver
+platform = android,ios
+timeout = 3s
+badport = abc
+
+[server.tls]
+cert = server.pem
+`
+
+type tlsSettings struct {
+	Cert string
+	Key  string `config:",default=server.key"`
+}
+
+type serverSettings struct {
+	Name     string        `config:"name,required"`
+	Port     int           `config:"port,default=8080"`
+	Platform []string      `config:"platform"`
+	Timeout  time.Duration `config:"timeout"`
+	Ratio    float64       `config:"ratio,default=0.5"`
+	Hosts    []string      `config:"hosts,default=a,b,c"`
+	TLS      tlsSettings   `config:"tls"`
+	Ignored  string        `config:"-"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	iniconf, err := NewConfigData(IniProtocol, []byte(unmarshalTestData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s serverSettings
+	if err := Unmarshal(iniconf, "server", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "testserver" || s.Port != 8080 || s.Ratio != 0.5 {
+		t.Errorf("settings = %+v", s)
+	}
+	if len(s.Platform) != 2 || s.Platform[1] != "ios" {
+		t.Errorf("platform = %q", s.Platform)
+	}
+	if len(s.Hosts) != 3 {
+		t.Errorf("hosts = %q", s.Hosts)
+	}
+	if s.Timeout != 3*time.Second {
+		t.Errorf("timeout = %v", s.Timeout)
+	}
+	if s.TLS.Cert != "server.pem" || s.TLS.Key != "server.key" {
+		t.Errorf("tls = %+v", s.TLS)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	iniconf, err := NewConfigData(IniProtocol, []byte(unmarshalTestData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s struct {
+		Port    int    `config:"badport"`
+		Missing string `config:"missing,required"`
+		Timeout int    `config:"timeout"`
+	}
+	err = Unmarshal(iniconf, "server", &s)
+	errs, ok := err.(UnmarshalErrors)
+	if !ok {
+		t.Fatalf("err = %v", err)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("len(errs) = %d, %v", len(errs), errs)
+	}
+	if !errors.Is(errs[1], ErrMissingKey) || errs[1].Key != "server.missing" {
+		t.Errorf("errs[1] = %v", errs[1])
+	}
+}
@@ -0,0 +1,268 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPollInterval = 2 * time.Second
+	reloadDelay         = 50 * time.Millisecond // coalesces bursts of file events
+)
+
+// Reloadable is a Configurer whose source files can be read again.
+type Reloadable interface {
+	Configurer
+	Files() []string
+	Reload() (old Configurer, changed []string, err error)
+}
+
+// ChangeFunc receives a snapshot of the configuration before the reload and
+// the live configuration after it.
+type ChangeFunc func(old, new Configurer)
+
+type subscription struct {
+	name string
+	fn   ChangeFunc
+}
+
+// Watcher re-parses a configuration when its file changes and notifies the
+// subscribers of the keys or sections that changed. It uses inotify where
+// available and falls back to polling the file's size and mtime.
+type Watcher struct {
+	cfg      Reloadable
+	interval time.Duration
+
+	mu      sync.Mutex
+	subs    []subscription
+	onError func(error)
+
+	reloadMu sync.Mutex // serializes Reload
+
+	notify    chan struct{}
+	closing   chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	stopMu sync.Mutex
+	stop   func()
+	files  []string // watched with inotify, nil when polling
+	closed bool
+}
+
+// NewWatcher starts watching c, which must be Reloadable. interval is the
+// polling period used when file notifications are unavailable; zero means
+// two seconds.
+func NewWatcher(c Configurer, interval time.Duration) (*Watcher, error) {
+	rc, ok := c.(Reloadable)
+	if !ok {
+		return nil, fmt.Errorf("config: %T does not support reloading", c)
+	}
+	if len(rc.Files()) == 0 {
+		return nil, fmt.Errorf("config: %T was not read from a file", c)
+	}
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	w := &Watcher{
+		cfg:      rc,
+		interval: interval,
+		notify:   make(chan struct{}, 1),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	files := rc.Files()
+	stop, err := startNotify(files, w.trigger)
+	if err != nil {
+		stop = w.startPoll(files)
+	} else {
+		w.files = files
+	}
+	w.stop = stop
+
+	go w.loop()
+	return w, nil
+}
+
+// OnChange calls fn after a reload that changed name, which is either a
+// section.key, a section (any key inside it) or "" for every change.
+func (w *Watcher) OnChange(name string, fn ChangeFunc) {
+	w.mu.Lock()
+	w.subs = append(w.subs, subscription{strings.ToLower(name), fn})
+	w.mu.Unlock()
+}
+
+// OnError sets the function called when a reload fails. The old data is
+// kept in that case.
+func (w *Watcher) OnError(fn func(error)) {
+	w.mu.Lock()
+	w.onError = fn
+	w.mu.Unlock()
+}
+
+// Reload re-reads the configuration now and notifies the subscribers.
+// Reloads run one at a time, so subscribers must not call Reload.
+func (w *Watcher) Reload() error {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+	old, changed, err := w.cfg.Reload()
+	if err != nil {
+		return err
+	}
+	w.rearm()
+	if len(changed) == 0 {
+		return nil
+	}
+
+	w.mu.Lock()
+	subs := make([]subscription, len(w.subs))
+	copy(subs, w.subs)
+	w.mu.Unlock()
+
+	for _, sub := range subs {
+		if matchChanged(sub.name, changed) {
+			sub.fn(old, w.cfg)
+		}
+	}
+	return nil
+}
+
+// rearm watches the files of the configuration again after a reload, as an
+// include may have been added. Polling reads the list on every tick.
+func (w *Watcher) rearm() {
+	w.stopMu.Lock()
+	defer w.stopMu.Unlock()
+	if w.closed || w.files == nil {
+		return
+	}
+	files := w.cfg.Files()
+	if reflect.DeepEqual(files, w.files) {
+		return
+	}
+	stop, err := startNotify(files, w.trigger)
+	if err != nil {
+		return // keep the old watches
+	}
+	w.stop()
+	w.stop, w.files = stop, files
+}
+
+// Close stops watching. It is safe to call more than once.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.closing)
+		w.stopMu.Lock()
+		w.closed = true
+		w.stop()
+		w.stopMu.Unlock()
+		<-w.done
+	})
+	return nil
+}
+
+func (w *Watcher) trigger() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Watcher) loop() {
+	defer close(w.done)
+	for {
+		select {
+		case <-w.closing:
+			return
+		case <-w.notify:
+		}
+
+		// editors often truncate then write, give them a moment
+		select {
+		case <-w.closing:
+			return
+		case <-time.After(reloadDelay):
+		}
+		select {
+		case <-w.notify:
+		default:
+		}
+
+		if err := w.Reload(); err != nil {
+			w.mu.Lock()
+			onError := w.onError
+			w.mu.Unlock()
+			if onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+type fileStamp struct {
+	size    int64
+	modtime time.Time
+}
+
+func statFiles(files []string) []fileStamp {
+	stamps := make([]fileStamp, len(files))
+	for i, name := range files {
+		if fi, err := os.Stat(name); err == nil {
+			stamps[i] = fileStamp{fi.Size(), fi.ModTime()}
+		}
+	}
+	return stamps
+}
+
+func (w *Watcher) startPoll(files []string) func() {
+	quit := make(chan struct{})
+	last := statFiles(files)
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+			}
+			// the file list can grow with includes after a reload
+			files = w.cfg.Files()
+			cur := statFiles(files)
+			if !sameStamps(last, cur) {
+				w.trigger()
+			}
+			last = cur
+		}
+	}()
+	return func() { close(quit) }
+}
+
+func sameStamps(a, b []fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].size != b[i].size || !a[i].modtime.Equal(b[i].modtime) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchChanged(name string, changed []string) bool {
+	if name == "" {
+		return true
+	}
+	for _, key := range changed {
+		if key == name || strings.HasPrefix(key, name+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// startNotify watches the directories of files with inotify, so that editors
+// replacing the file by rename are noticed as well.
+func startNotify(files []string, trigger func()) (func(), error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// a non-blocking fd goes through the runtime poller, so Close unblocks Read
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	names := make(map[int32]map[string]bool)
+	const mask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE | syscall.IN_MODIFY
+	for _, name := range files {
+		abs, err := filepath.Abs(name)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		wd, err := syscall.InotifyAddWatch(fd, filepath.Dir(abs), mask)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if names[int32(wd)] == nil {
+			names[int32(wd)] = make(map[string]bool)
+		}
+		names[int32(wd)][filepath.Base(abs)] = true
+	}
+
+	go func() {
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+			for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+				ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+				nameBytes := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+				off += syscall.SizeofInotifyEvent + int(ev.Len)
+
+				name := string(nameBytes)
+				for i := 0; i < len(name); i++ {
+					if name[i] == 0 {
+						name = name[:i]
+						break
+					}
+				}
+				if names[ev.Wd][name] {
+					trigger()
+				}
+			}
+		}
+	}()
+
+	return func() { f.Close() }, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package config
+
+import "errors"
+
+func startNotify(files []string, trigger func()) (func(), error) {
+	return nil, errors.New("config: file notification not supported")
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watchtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "app.ini")
+	if err := ioutil.WriteFile(name, []byte("[server]\nport = 8080\n[log]\nlevel = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	iniconf, err := NewConfig(IniProtocol, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWatcher(iniconf, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	ports := make(chan [2]int, 1)
+	w.OnChange("server.port", func(old, new Configurer) {
+		ports <- [2]int{old.GetInt("server.port"), new.GetInt("server.port")}
+	})
+	w.OnChange("log", func(old, new Configurer) {
+		t.Error("log section did not change")
+	})
+
+	if err := ioutil.WriteFile(name, []byte("[server]\nport = 9090\n[log]\nlevel = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case p := <-ports:
+		if p[0] != 8080 || p[1] != 9090 {
+			t.Errorf("port change = %v", p)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no change notification")
+	}
+	if port := iniconf.GetInt("server.port"); port != 9090 {
+		t.Errorf("server.port = %d", port)
+	}
+}
+
+func TestWatcherPoll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watchtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "app.ini")
+	if err := ioutil.WriteFile(name, []byte("[server]\nport = 8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	iniconf, err := NewConfig(IniProtocol, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Watcher{
+		cfg:      iniconf.(Reloadable),
+		interval: 10 * time.Millisecond,
+		notify:   make(chan struct{}, 1),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	w.stop = w.startPoll([]string{name})
+	go w.loop()
+	defer w.Close()
+
+	changed := make(chan bool, 1)
+	w.OnChange("server", func(old, new Configurer) { changed <- true })
+
+	if err := ioutil.WriteFile(name, []byte("[server]\nport = 80800\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("no change notification")
+	}
+}
+
+func TestWatcherInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watchtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "app.ini")
+	extra := filepath.Join(dir, "extra.ini")
+	if err := ioutil.WriteFile(name, []byte("[server]\nport = 8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(extra, []byte("[log]\nlevel = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	iniconf, err := NewConfig(IniProtocol, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWatcher(iniconf, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	changed := make(chan string, 4)
+	w.OnChange("", func(old, new Configurer) { changed <- new.GetString("log.level") })
+	wait := func(want string) {
+		select {
+		case level := <-changed:
+			if level != want {
+				t.Errorf("log.level = %q, want %q", level, want)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("no change notification")
+		}
+	}
+
+	if err := ioutil.WriteFile(name, []byte("include = extra.ini\n[server]\nport = 8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wait("1")
+	// the include added by the reload is watched as well
+	if err := ioutil.WriteFile(extra, []byte("[log]\nlevel = 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wait("2")
+}
@@ -1,6 +1,7 @@
 package httplib
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -19,6 +20,9 @@ type Config struct {
 	ReadTimeout    time.Duration
 	WriteTimeout   time.Duration
 	MaxHeaderBytes int
+	// HandlerTimeout cancels r.Context() of a request still handled after
+	// it, aborting SQL run with that context; defaults to WriteTimeout.
+	HandlerTimeout time.Duration
 }
 
 //type FuncHandler func(w http.ResponseWriter, req *http.Request)
@@ -35,6 +39,9 @@ func NewServer(cfg Config) *HttpServer {
 	if cfg.WriteTimeout == 0 {
 		cfg.WriteTimeout = 10 * time.Second
 	}
+	if cfg.HandlerTimeout == 0 {
+		cfg.HandlerTimeout = cfg.WriteTimeout
+	}
 	if cfg.MaxHeaderBytes == 0 {
 		cfg.MaxHeaderBytes = http.DefaultMaxHeaderBytes
 	}
@@ -79,7 +86,9 @@ func (hs *HttpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		logger.Infof(">>>Start %s %s for %s", r.Method, r.URL.Path, addr)
-		handler(w, r)
+		ctx, cancel := context.WithTimeout(r.Context(), hs.config.HandlerTimeout)
+		defer cancel()
+		handler(w, r.WithContext(ctx))
 		logger.Infof(">>>End %s %s for %s in %v\n", r.Method, r.URL.Path, addr, time.Since(start))
 	} else {
 		ServerNotFound(w, r)
@@ -20,7 +20,7 @@ func Init(port uint16) {
 		Host: "0.0.0.0",
 		Port: port,
 	}
-	monitor := httplib.NewServer(cfg)
+	monitor = httplib.NewServer(cfg)
 	monitor.HandleFunc("/SetLogLevel", setLogLevel)
 	monitor.ListenAndServe()
 }
@@ -0,0 +1,276 @@
+package mysqlz
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DefaultBatchSize and DefaultMaxPacket are the limits BulkInsert uses for
+// a zero BulkOptions field. DefaultMaxPacket is the smallest default
+// max_allowed_packet of the servers in use.
+var (
+	DefaultBatchSize = 1000
+	DefaultMaxPacket = 4 << 20
+)
+
+// maxPlaceholders is the most ? a prepared statement may have.
+const maxPlaceholders = 65535
+
+// BulkOptions configures BulkInsert.
+type BulkOptions struct {
+	BatchSize int // rows per INSERT
+	MaxPacket int // bytes per INSERT, keep below the server's max_allowed_packet
+}
+
+// BulkInsert adds rows with as few multi-row INSERTs as BatchSize and
+// MaxPacket allow, honouring Ignore and OnDuplicateKeyUpdate, and returns
+// the number of rows affected. The INSERTs are not atomic: run it on a
+// TxModel for that. On error the count covers the INSERTs that succeeded.
+func (m *Model) BulkInsert(rows []map[string]interface{}, opts BulkOptions) (int, error) {
+	return m.BulkInsertContext(m.context(), rows, opts)
+}
+
+func (m *Model) BulkInsertContext(ctx context.Context, rows []map[string]interface{}, opts BulkOptions) (int, error) {
+	if opts.BatchSize < 1 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.MaxPacket < 1 {
+		opts.MaxPacket = DefaultMaxPacket
+	}
+	total := 0
+	for start := 0; start < len(rows); {
+		end := start + batchLen(rows[start:], opts)
+		query, args := m.insertSQL(rows[start:end]...)
+		n, err := m.affected(ctx, query, args)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		start = end
+	}
+	return total, nil
+}
+
+// batchLen returns how many of rows, at least one, fit in the next INSERT.
+// It estimates the packet size by the size of the values.
+func batchLen(rows []map[string]interface{}, opts BulkOptions) int {
+	size, marks := 0, 0
+	for i, row := range rows {
+		rowSize := 4
+		for key, v := range row {
+			rowSize += len(key) + valueSize(v) + 4
+		}
+		if i > 0 && (i == opts.BatchSize || size+rowSize > opts.MaxPacket || marks+len(row) > maxPlaceholders) {
+			return i
+		}
+		size += rowSize
+		marks += len(row)
+	}
+	return len(rows)
+}
+
+func valueSize(v interface{}) int {
+	switch v := v.(type) {
+	case nil:
+		return 1
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	case Expr:
+		n := len(v.sql)
+		for _, arg := range v.args {
+			n += valueSize(arg)
+		}
+		return n
+	}
+	return 16
+}
+
+// LoadOptions configures LoadData. The zero value reads CSV: fields
+// separated by commas and optionally enclosed in double quotes, lines
+// ending in "\n", \N for NULL.
+type LoadOptions struct {
+	Columns     []string // the columns of the fields, all of the table if empty
+	Separator   string   // default ","
+	Enclosure   string   // default `"`
+	LineEnd     string   // default "\n"
+	IgnoreLines int      // header lines to skip
+	Replace     bool     // replace rows on a duplicate key instead of skipping them
+}
+
+var readerSeq uint64
+
+// LoadData loads r into the table with LOAD DATA LOCAL INFILE and returns
+// the number of rows affected. The server must have local_infile enabled.
+// Rows hitting a duplicate key are skipped, see LoadOptions.Replace.
+func (m *Model) LoadData(r io.Reader, opts LoadOptions) (int, error) {
+	return m.LoadDataContext(m.context(), r, opts)
+}
+
+func (m *Model) LoadDataContext(ctx context.Context, r io.Reader, opts LoadOptions) (int, error) {
+	name := fmt.Sprintf("litego_%d", atomic.AddUint64(&readerSeq, 1))
+	mysql.RegisterReaderHandler(name, func() io.Reader { return r })
+	defer mysql.DeregisterReaderHandler(name)
+	return m.affected(ctx, m.loadSQL("Reader::"+name, opts), nil)
+}
+
+func (m *Model) loadSQL(file string, opts LoadOptions) string {
+	if opts.Separator == "" {
+		opts.Separator = ","
+	}
+	if opts.Enclosure == "" {
+		opts.Enclosure = `"`
+	}
+	if opts.LineEnd == "" {
+		opts.LineEnd = "\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "LOAD DATA LOCAL INFILE %s ", quoteString(file))
+	if opts.Replace {
+		b.WriteString("REPLACE ")
+	} else {
+		b.WriteString("IGNORE ")
+	}
+	fmt.Fprintf(&b, "INTO TABLE %s FIELDS TERMINATED BY %s OPTIONALLY ENCLOSED BY %s LINES TERMINATED BY %s",
+		quoteIdent(m.table), quoteString(opts.Separator), quoteString(opts.Enclosure), quoteString(opts.LineEnd))
+	if opts.IgnoreLines > 0 {
+		fmt.Fprintf(&b, " IGNORE %d LINES", opts.IgnoreLines)
+	}
+	if len(opts.Columns) > 0 {
+		fmt.Fprintf(&b, " (%s)", quoteIdents(opts.Columns))
+	}
+	return b.String()
+}
+
+// quoteString returns s as a string literal.
+func quoteString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "\x00", `\0`)
+	return "'" + r.Replace(s) + "'"
+}
+
+// ExportFormat is the output format of Export.
+type ExportFormat int
+
+const (
+	// CSV writes one line per row, without a header, with NULL as \N and
+	// backslashes doubled, which LoadData reads back with the zero
+	// LoadOptions, or with Columns naming the selected columns.
+	CSV ExportFormat = iota
+	// JSONLines writes one JSON object per row, with numeric columns as
+	// numbers and NULL as null.
+	JSONLines
+)
+
+// Export streams the rows of the query to w in format, one at a time, and
+// returns the number of rows written. The query timeout of the pool bounds
+// the whole export, so long exports need a pool without one.
+func (m *Model) Export(w io.Writer, format ExportFormat) (int64, error) {
+	return m.ExportContext(m.context(), w, format)
+}
+
+func (m *Model) ExportContext(ctx context.Context, w io.Writer, format ExportFormat) (n int64, err error) {
+	if format != CSV && format != JSONLines {
+		return 0, fmt.Errorf("mysqlz: unknown export format %d", format)
+	}
+	query, args := m.ToSQL()
+	rows, done, err := m.query(ctx, query, args)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		rows.Close()
+		done(n, err)
+		err = wrapError(query, args, err)
+	}()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+	cols := make([]string, len(types))
+	for i, t := range types {
+		cols[i] = t.Name()
+	}
+	values := make([]sql.RawBytes, len(cols))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	var write func() error
+	var flush func() error
+	switch format {
+	case CSV:
+		cw := csv.NewWriter(w)
+		record := make([]string, len(cols))
+		write = func() error {
+			for i, v := range values {
+				if v == nil {
+					record[i] = `\N`
+				} else {
+					record[i] = strings.Replace(string(v), `\`, `\\`, -1)
+				}
+			}
+			return cw.Write(record)
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		bw := bufio.NewWriter(w)
+		numeric := make([]bool, len(types))
+		for i, t := range types {
+			numeric[i] = isNumeric(t.DatabaseTypeName())
+		}
+		obj := make(map[string]interface{}, len(cols))
+		enc := json.NewEncoder(bw)
+		enc.SetEscapeHTML(false)
+		write = func() error {
+			for i, v := range values {
+				switch {
+				case v == nil:
+					obj[cols[i]] = nil
+				case numeric[i]:
+					obj[cols[i]] = json.RawMessage(v)
+				default:
+					obj[cols[i]] = string(v)
+				}
+			}
+			return enc.Encode(obj)
+		}
+		flush = bw.Flush
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return n, err
+		}
+		if err := write(); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	return n, flush()
+}
+
+func isNumeric(dbType string) bool {
+	switch strings.TrimPrefix(dbType, "UNSIGNED ") {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "DECIMAL", "FLOAT", "DOUBLE", "YEAR":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package mysqlz
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/csv"
+	"litego/mysqlz/mysqlztest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBulkInsert(t *testing.T) {
+	fake := mysqlztest.New()
+	logs := NewMysqlConnPoolFromDB(fake.DB()).GetModel().SetTable("log")
+
+	var rows []map[string]interface{}
+	for _, msg := range []string{"a", "b", "c", "d", "e"} {
+		rows = append(rows, map[string]interface{}{"msg": msg})
+	}
+	fake.Expect("INSERT INTO `log` (`msg`) VALUES (?), (?)").WithArgs("a", "b").WillReturnResult(0, 2)
+	fake.Expect("INSERT INTO `log` (`msg`) VALUES (?), (?)").WithArgs("c", "d").WillReturnResult(0, 2)
+	fake.Expect("INSERT INTO `log` (`msg`) VALUES (?)").WithArgs("e").WillReturnResult(0, 1)
+	if n, err := logs.BulkInsert(rows, BulkOptions{BatchSize: 2}); err != nil || n != 5 {
+		t.Errorf("BulkInsert = %d, %v", n, err)
+	}
+
+	// a packet of 50 bytes holds two of these rows
+	rows = []map[string]interface{}{{"msg": "0123456789"}, {"msg": "0123456789"}, {"msg": "0123456789"}}
+	fake.Expect("INSERT INTO `log` (`msg`) VALUES (?), (?)").WillReturnResult(0, 2)
+	fake.Expect("INSERT INTO `log` (`msg`) VALUES (?)").WillReturnResult(0, 1)
+	if n, err := logs.BulkInsert(rows, BulkOptions{MaxPacket: 50}); err != nil || n != 3 {
+		t.Errorf("BulkInsert by packet size = %d, %v", n, err)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBatchLen(t *testing.T) {
+	rows := make([]map[string]interface{}, 70000)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"id": i}
+	}
+	opts := BulkOptions{BatchSize: len(rows), MaxPacket: 1 << 30}
+	if n := batchLen(rows, opts); n != maxPlaceholders {
+		t.Errorf("batchLen = %d, want %d", n, maxPlaceholders)
+	}
+	opts.MaxPacket = 1
+	if n := batchLen(rows, opts); n != 1 {
+		t.Errorf("batchLen of oversized rows = %d, want 1", n)
+	}
+}
+
+func TestLoadData(t *testing.T) {
+	m := new(Model).SetTable("room")
+	got := m.loadSQL("Reader::x", LoadOptions{Columns: []string{"id", "name"}, Separator: "\t", IgnoreLines: 1, Replace: true})
+	want := `LOAD DATA LOCAL INFILE 'Reader::x' REPLACE INTO TABLE ` + "`room`" +
+		` FIELDS TERMINATED BY '\t' OPTIONALLY ENCLOSED BY '"' LINES TERMINATED BY '\n' IGNORE 1 LINES (` + "`id`, `name`)"
+	if got != want {
+		t.Errorf("loadSQL =\n%s\nwant\n%s", got, want)
+	}
+	if q := quoteString(`it's \`); q != `'it\'s \\'` {
+		t.Errorf("quoteString = %s", q)
+	}
+
+	fake := mysqlztest.New()
+	fake.ExpectRegexp(`^LOAD DATA LOCAL INFILE 'Reader::litego_\d+' IGNORE INTO TABLE`).WillReturnResult(0, 2)
+	rooms := NewMysqlConnPoolFromDB(fake.DB()).GetModel().SetTable("room")
+	if n, err := rooms.LoadData(strings.NewReader("1,a\n2,b\n"), LoadOptions{}); err != nil || n != 2 {
+		t.Errorf("LoadData = %d, %v", n, err)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	fake := mysqlztest.New()
+	rooms := NewMysqlConnPoolFromDB(fake.DB()).GetModel().SetTable("room").Fileds("id", "name")
+	cols := []string{"id", "name"}
+	data := [][]driver.Value{{int64(1), "a,b"}, {int64(2), nil}}
+	fake.Expect("SELECT `id`, `name` FROM `room`").Times(2).WillReturnRows(cols, data...)
+
+	var buf bytes.Buffer
+	if n, err := rooms.Export(&buf, CSV); err != nil || n != 2 {
+		t.Fatalf("Export CSV = %d, %v", n, err)
+	}
+	if want := "1,\"a,b\"\n2,\\N\n"; buf.String() != want {
+		t.Errorf("CSV = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	if n, err := rooms.Export(&buf, JSONLines); err != nil || n != 2 {
+		t.Fatalf("Export JSONLines = %d, %v", n, err)
+	}
+	if want := "{\"id\":\"1\",\"name\":\"a,b\"}\n{\"id\":\"2\",\"name\":null}\n"; buf.String() != want {
+		t.Errorf("JSON lines = %q, want %q", buf.String(), want)
+	}
+
+	if _, err := rooms.Export(&buf, ExportFormat(9)); err == nil {
+		t.Error("Export of an unknown format succeeded")
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// loadField reads a CSV field the way LOAD DATA does with its default
+// FIELDS ESCAPED BY '\\', returning nil for NULL.
+func loadField(f string) interface{} {
+	if f == `\N` {
+		return nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(f); i++ {
+		if f[i] == '\\' && i+1 < len(f) {
+			i++
+			switch f[i] {
+			case '0':
+				b.WriteByte(0)
+			case 'b':
+				b.WriteByte('\b')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'Z':
+				b.WriteByte(26)
+			default:
+				b.WriteByte(f[i])
+			}
+			continue
+		}
+		b.WriteByte(f[i])
+	}
+	return b.String()
+}
+
+func TestExportLoadRoundTrip(t *testing.T) {
+	fake := mysqlztest.New()
+	pool := NewMysqlConnPoolFromDB(fake.DB())
+	files := pool.GetModel().SetTable("file").Fileds("path")
+	want := []interface{}{`C:\temp`, `\N`, nil, `a"b\`, "x\ny"}
+	var data [][]driver.Value
+	for _, v := range want {
+		data = append(data, []driver.Value{v})
+	}
+	fake.Expect("SELECT `path` FROM `file`").WillReturnRows([]string{"path"}, data...)
+
+	var buf bytes.Buffer
+	if _, err := files.Export(&buf, CSV); err != nil {
+		t.Fatal(err)
+	}
+	exported := buf.String()
+
+	// LoadData reads the export back with the zero LoadOptions: every
+	// line is a row, none is skipped as a header
+	fake.ExpectRegexp(`^LOAD DATA LOCAL INFILE 'Reader::litego_\d+' IGNORE INTO TABLE `+"`copy`"+
+		` FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '"' LINES TERMINATED BY '\\n'$`).WillReturnResult(0, int64(len(want)))
+	if n, err := pool.GetModel().SetTable("copy").LoadData(&buf, LoadOptions{}); err != nil || n != len(want) {
+		t.Errorf("LoadData = %d, %v", n, err)
+	}
+	records, err := csv.NewReader(strings.NewReader(exported)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []interface{}
+	for _, r := range records {
+		got = append(got, loadField(r[0]))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %q, want %q", got, want)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,455 @@
+package mysqlz
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueryCache is a read-through cache of query results shared by the Models
+// of a pool, see MysqlConnPool.SetCache and Model.Cached. Entries are keyed
+// by the statement and its args, expire after a TTL and are evicted least
+// recently used first beyond the size bound. Concurrent misses of the same
+// key run the query once. Insert, Update, Delete and the other builder
+// writes of the pool's Models drop the entries reading the tables they
+// write, joins and subqueries included, whatever the database prefix or
+// case of the names. Statements whose tables cannot be told are not cached
+// or, for writes, drop every entry, as do raw statements run by
+// Model.Query and stored procedures run by Call. Writes in a
+// transaction take effect once it commits. Writes by other processes are
+// only seen when the entries expire.
+type QueryCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	ll      *list.List // of *cacheEntry, most recently used first
+	items   map[string]*list.Element
+	tables  map[string]map[string]struct{} // table -> keys reading it
+	flights map[string]*flight
+	seq     uint64            // counts invalidations
+	dirty   map[string]uint64 // table -> seq of its last invalidation
+	purged  uint64            // seq of the last Purge
+	stats   CacheStats
+}
+
+// CacheStats counts the lookups of a QueryCache. Shared counts the misses
+// served by the query of a concurrent identical miss.
+type CacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Shared  int64 `json:"shared"`
+	Entries int   `json:"entries"`
+}
+
+type cacheEntry struct {
+	key     string
+	value   reflect.Value
+	tables  []string
+	expires time.Time
+}
+
+type flight struct {
+	done     chan struct{}
+	value    reflect.Value
+	err      error
+	canceled bool // err is due to the context of the loading caller
+}
+
+// NewQueryCache returns a cache of at most size entries kept for ttl.
+func NewQueryCache(size int, ttl time.Duration) *QueryCache {
+	if size < 1 {
+		size = 1
+	}
+	return &QueryCache{
+		size:    size,
+		ttl:     ttl,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+		tables:  make(map[string]map[string]struct{}),
+		flights: make(map[string]*flight),
+		dirty:   make(map[string]uint64),
+	}
+}
+
+// SetCache makes the Models created afterwards able to cache their reads,
+// see Model.Cached, and invalidate c on their writes; nil turns it off.
+func (m *MysqlConnPool) SetCache(c *QueryCache) {
+	m.cache = c
+}
+
+// Cached makes FindAll, Find, FindOne and Count answer from the cache of
+// the pool for ttl, the cache's TTL if 0. Results are copied, so callers
+// may modify them. It does nothing without a cache and in transactions.
+func (m *Model) Cached(ttl time.Duration) *Model {
+	c := m.clone()
+	c.cached = true
+	c.cacheTTL = ttl
+	return c
+}
+
+// Invalidate drops the entries reading any of tables, see tableKey.
+func (c *QueryCache) Invalidate(tables ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	for _, t := range tables {
+		t = tableKey(t)
+		c.dirty[t] = c.seq
+		for key := range c.tables[t] {
+			c.remove(c.items[key])
+		}
+	}
+}
+
+// Purge drops every entry.
+func (c *QueryCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	c.purged = c.seq
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	c.tables = make(map[string]map[string]struct{})
+}
+
+// Stats returns the lookup counts and the number of entries.
+func (c *QueryCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = c.ll.Len()
+	return s
+}
+
+// get returns the value cached for key, running load with ctx on a miss.
+// A value loaded while one of its tables was invalidated is returned but
+// not kept, as it may predate the write. Callers waiting for the load of
+// another stop when their own ctx is done, and load again themselves if
+// that of the other was cancelled.
+func (c *QueryCache) get(ctx context.Context, key string, tables []string, ttl time.Duration, load func() (reflect.Value, error)) (reflect.Value, error) {
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*cacheEntry)
+		if time.Now().Before(e.expires) {
+			c.ll.MoveToFront(el)
+			c.stats.Hits++
+			c.mu.Unlock()
+			return e.value, nil
+		}
+		c.remove(el)
+	}
+	if f, ok := c.flights[key]; ok {
+		c.stats.Shared++
+		c.mu.Unlock()
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return reflect.Value{}, ctx.Err()
+		}
+		if f.canceled {
+			return c.get(ctx, key, tables, ttl, load)
+		}
+		return f.value, f.err
+	}
+	f := &flight{done: make(chan struct{})}
+	c.flights[key] = f
+	c.stats.Misses++
+	seq := c.seq
+	c.mu.Unlock()
+
+	f.err = fmt.Errorf("mysqlz: cache load of %q panicked", key)
+	defer func() {
+		c.mu.Lock()
+		delete(c.flights, key)
+		if f.err == nil && !c.stale(tables, seq) {
+			if ttl <= 0 {
+				ttl = c.ttl
+			}
+			c.add(&cacheEntry{key: key, value: f.value, tables: tables, expires: time.Now().Add(ttl)})
+		}
+		c.mu.Unlock()
+		close(f.done)
+	}()
+	f.value, f.err = load()
+	f.canceled = f.err != nil && ctx.Err() != nil
+	return f.value, f.err
+}
+
+func (c *QueryCache) stale(tables []string, seq uint64) bool {
+	if c.purged > seq {
+		return true
+	}
+	for _, t := range tables {
+		if c.dirty[t] > seq {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *QueryCache) add(e *cacheEntry) {
+	if el, ok := c.items[e.key]; ok {
+		c.remove(el)
+	}
+	c.items[e.key] = c.ll.PushFront(e)
+	for _, t := range e.tables {
+		keys, ok := c.tables[t]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tables[t] = keys
+		}
+		keys[e.key] = struct{}{}
+	}
+	for c.ll.Len() > c.size {
+		c.remove(c.ll.Back())
+	}
+}
+
+func (c *QueryCache) remove(el *list.Element) {
+	e := c.ll.Remove(el).(*cacheEntry)
+	delete(c.items, e.key)
+	for _, t := range e.tables {
+		delete(c.tables[t], e.key)
+		if len(c.tables[t]) == 0 {
+			delete(c.tables, t)
+		}
+	}
+}
+
+// cachedRead runs load with dest, a pointer, or with Cached fills dest
+// with a copy of the cached result of query. The key includes the type of
+// dest, as the same query scanned into another type is another result.
+func (m *Model) cachedRead(ctx context.Context, query string, args []interface{}, dest interface{}, load func(ctx context.Context, dest interface{}) error) error {
+	if !m.cached || m.cache == nil {
+		return load(ctx, dest)
+	}
+	tables, ok := sqlTables(query)
+	if !ok {
+		// its entry could not be invalidated
+		return load(ctx, dest)
+	}
+	t := reflect.TypeOf(dest).Elem()
+	key := cacheKey(t, query, args)
+	v, err := m.cache.get(ctx, key, tables, m.cacheTTL, func() (reflect.Value, error) {
+		p := reflect.New(t)
+		if err := load(ctx, p.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+		return p.Elem(), nil
+	})
+	if err != nil {
+		return err
+	}
+	reflect.ValueOf(dest).Elem().Set(deepCopy(v))
+	return nil
+}
+
+func cacheKey(t reflect.Type, query string, args []interface{}) string {
+	var b strings.Builder
+	b.WriteString(t.String())
+	b.WriteByte(0)
+	b.WriteString(strings.Join(strings.Fields(query), " "))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "\x00%T:%v", arg, arg)
+	}
+	return b.String()
+}
+
+// sqlTables returns the tables query reads or writes, by the names after
+// FROM, JOIN, UPDATE and INTO in it and its subqueries, normalized by
+// tableKey. It returns false when it cannot tell them all, e.g. for a FROM
+// followed by something other than a table.
+func sqlTables(query string) ([]string, bool) {
+	toks := sqlTokens(query)
+	var tables []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(toks); i++ {
+		kw := strings.ToUpper(toks[i])
+		if kw != "FROM" && kw != "JOIN" && kw != "UPDATE" && kw != "INTO" {
+			continue
+		}
+		j := i + 1
+		if kw == "INTO" && j < len(toks) && strings.EqualFold(toks[j], "TABLE") {
+			j++
+		}
+		for {
+			if j >= len(toks) || toks[j] != "(" && !isIdent(toks[j]) {
+				return nil, false
+			}
+			if toks[j] == "(" {
+				break // a subquery, read on its own
+			}
+			if t := tableKey(toks[j]); !seen[t] {
+				seen[t] = true
+				tables = append(tables, t)
+			}
+			// FROM and UPDATE take a list of tables, each with an alias
+			j++
+			if j < len(toks) && strings.EqualFold(toks[j], "AS") {
+				j++
+			}
+			if j+1 < len(toks) && isIdent(toks[j]) && toks[j+1] == "," {
+				j++
+			}
+			if j >= len(toks) || toks[j] != "," || kw == "JOIN" || kw == "INTO" {
+				break
+			}
+			j++
+		}
+	}
+	return tables, true
+}
+
+// sqlTokens splits query into words, such as `db`.`t`, and single
+// punctuation characters; string literals become "'".
+func sqlTokens(query string) []string {
+	var toks []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			i++
+			for i < len(query) && query[i] != c {
+				if query[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			i++
+			toks = append(toks, "'")
+		case isWordByte(c) || c == '`':
+			j := i
+			for j < len(query) && (isWordByte(query[j]) || query[j] == '.' || query[j] == '`') {
+				if query[j] == '`' {
+					end := strings.IndexByte(query[j+1:], '`')
+					if end < 0 {
+						j = len(query)
+						break
+					}
+					j += end + 2
+					continue
+				}
+				j++
+			}
+			toks = append(toks, query[i:j])
+			i = j
+		default:
+			toks = append(toks, query[i:i+1])
+			i++
+		}
+	}
+	return toks
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isIdent(tok string) bool {
+	return tok != "" && (tok[0] == '`' || tok[0] == '_' || tok[0] == '$' || 'a' <= tok[0]|0x20 && tok[0]|0x20 <= 'z')
+}
+
+// tableKey returns the table of a name such as `db`.`Room` as the cache
+// tags it: unquoted, without the database and in lower case, so that every
+// spelling of a table invalidates the same entries.
+func tableKey(name string) string {
+	name = strings.Replace(name, "`", "", -1)
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.ToLower(name)
+}
+
+// written invalidates the tables of query after m ran it, or every table
+// if they are not all known.
+func (m *Model) written(query string) {
+	if m.invalidate == nil {
+		return
+	}
+	tables, ok := sqlTables(query)
+	if !ok {
+		m.invalidate(nil)
+	} else if len(tables) > 0 {
+		m.invalidate(tables)
+	}
+}
+
+// writtenAny invalidates every table after a statement that may have
+// written any of them.
+func (m *Model) writtenAny() {
+	if m.invalidate != nil {
+		m.invalidate(nil)
+	}
+}
+
+// invalidate drops the entries of tables, or every entry for nil.
+func (c *QueryCache) invalidate(tables []string) {
+	if tables == nil {
+		c.Purge()
+		return
+	}
+	c.Invalidate(tables...)
+}
+
+// deepCopy copies v so that the copy shares no pointers, slices or maps
+// with it. Unexported fields, such as those of time.Time, are copied
+// shallowly.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if f := c.Field(i); f.CanSet() {
+				f.Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	}
+	return v
+}
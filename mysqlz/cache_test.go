@@ -0,0 +1,273 @@
+package mysqlz
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"litego/mysqlz/mysqlztest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueryCacheSingleFlight(t *testing.T) {
+	c := NewQueryCache(10, time.Minute)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	loads := 0
+	load := func() (reflect.Value, error) {
+		mu.Lock()
+		loads++
+		mu.Unlock()
+		<-release
+		return reflect.ValueOf(42), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.get(context.Background(), "k", nil, 0, load); err != nil || v.Int() != 42 {
+				t.Errorf("get = %v, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if loads != 1 {
+		t.Errorf("loads = %d, want 1", loads)
+	}
+	if s := c.Stats(); s.Misses != 1 || s.Hits+s.Shared != 7 || s.Entries != 1 {
+		t.Errorf("Stats = %+v", s)
+	}
+}
+
+func TestQueryCacheCanceled(t *testing.T) {
+	c := NewQueryCache(10, time.Minute)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error)
+	go func() {
+		_, err := c.get(ctx, "k", nil, 0, func() (reflect.Value, error) {
+			close(started)
+			<-release
+			return reflect.Value{}, ctx.Err()
+		})
+		leader <- err
+	}()
+	<-started
+
+	// a waiter whose own context ends stops waiting
+	done, stop := context.WithCancel(context.Background())
+	stop()
+	if _, err := c.get(done, "k", nil, 0, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled waiter: err = %v", err)
+	}
+
+	// a live waiter loads again when the leader was cancelled
+	waiter := make(chan reflect.Value)
+	go func() {
+		v, err := c.get(context.Background(), "k", nil, 0, func() (reflect.Value, error) {
+			return reflect.ValueOf(7), nil
+		})
+		if err != nil {
+			t.Errorf("live waiter: err = %v", err)
+		}
+		waiter <- v
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	close(release)
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("leader: err = %v", err)
+	}
+	if v := <-waiter; !v.IsValid() || v.Int() != 7 {
+		t.Errorf("live waiter got %v", v)
+	}
+}
+
+func TestQueryCacheEviction(t *testing.T) {
+	c := NewQueryCache(2, time.Minute)
+	loads := 0
+	get := func(key string, ttl time.Duration) {
+		c.get(context.Background(), key, []string{"t_" + key}, ttl, func() (reflect.Value, error) {
+			loads++
+			return reflect.ValueOf(key), nil
+		})
+	}
+
+	get("a", 0)
+	get("b", 0)
+	get("a", 0) // a is now the most recently used
+	get("c", 0) // evicts b
+	get("a", 0)
+	if loads != 3 {
+		t.Errorf("loads = %d after LRU, want 3", loads)
+	}
+	get("b", 0)
+	if loads != 4 {
+		t.Errorf("loads = %d, b was not evicted", loads)
+	}
+
+	c.Invalidate("t_b")
+	get("b", 0)
+	if loads != 5 {
+		t.Errorf("loads = %d, b was not invalidated", loads)
+	}
+
+	get("d", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	get("d", 0)
+	if loads != 7 {
+		t.Errorf("loads = %d, d did not expire", loads)
+	}
+}
+
+func TestQueryCacheInvalidatedDuringLoad(t *testing.T) {
+	c := NewQueryCache(10, time.Minute)
+	c.get(context.Background(), "k", []string{"room"}, 0, func() (reflect.Value, error) {
+		c.Invalidate("room")
+		return reflect.ValueOf(1), nil
+	})
+	if s := c.Stats(); s.Entries != 0 {
+		t.Errorf("kept a result loaded across an invalidation: %+v", s)
+	}
+}
+
+type testRoom struct {
+	ID   int64
+	Name string
+	Tags []byte
+}
+
+func TestModelCached(t *testing.T) {
+	fake := mysqlztest.New()
+	pool := NewMysqlConnPoolFromDB(fake.DB())
+	pool.SetCache(NewQueryCache(100, time.Minute))
+	rooms := pool.GetModel().SetTable("room")
+	byID := rooms.Cached(0).Where("id = ?", 7)
+
+	sel := "SELECT * FROM `room` WHERE id = ? LIMIT 1"
+	cols := []string{"id", "name", "tags"}
+	fake.Expect(sel).WithArgs(7).WillReturnRows(cols, []driver.Value{int64(7), "lobby", []byte("a")})
+	var r testRoom
+	for i := 0; i < 3; i++ {
+		if err := byID.FindOne(&r); err != nil || r.Name != "lobby" {
+			t.Fatalf("FindOne = %+v, %v", r, err)
+		}
+		r.Tags[0] = 'x' // must not change the cached copy
+	}
+	var rs []testRoom
+	fake.Expect("SELECT * FROM `room` WHERE id = ?").WithArgs(7).
+		WillReturnRows(cols, []driver.Value{int64(7), "lobby", []byte("a")})
+	if err := byID.Find(&rs); err != nil || len(rs) != 1 || string(rs[0].Tags) != "a" {
+		t.Fatalf("Find = %+v, %v", rs, err)
+	}
+	if err := byID.FindOne(&r); err != nil || string(r.Tags) != "a" {
+		t.Fatalf("FindOne returned a modified result: %+v, %v", r, err)
+	}
+
+	fake.Expect("UPDATE `room` SET `name` = ? WHERE id = ?").WithArgs("hall", 7).WillReturnResult(0, 1)
+	if _, err := rooms.Where("id = ?", 7).Update(map[string]interface{}{"name": "hall"}); err != nil {
+		t.Fatal(err)
+	}
+	fake.Expect(sel).WithArgs(7).WillReturnRows(cols, []driver.Value{int64(7), "hall", nil})
+	if err := byID.FindOne(&r); err != nil || r.Name != "hall" {
+		t.Errorf("FindOne after Update = %+v, %v", r, err)
+	}
+
+	// writes in a transaction invalidate once it commits
+	fake.Expect("DELETE FROM `room` WHERE id = ?").WithArgs(7).WillReturnResult(0, 1)
+	err := pool.Tx(context.Background(), func(tx *TxModel) error {
+		_, err := tx.GetModel().SetTable("room").Delete("id = ?", 7)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.Expect(sel).WithArgs(7)
+	if err := byID.FindOne(&r); err != ErrNotFound {
+		t.Errorf("FindOne after Delete: err = %v", err)
+	}
+
+	// raw writes may touch any table and drop everything
+	fake.Expect(sel).WithArgs(7).WillReturnRows(cols, []driver.Value{int64(7), "hall", nil})
+	fake.Expect("UPDATE room SET name = ? WHERE id = ?").WithArgs("gym", 7).WillReturnResult(0, 1)
+	fake.Expect(sel).WithArgs(7).WillReturnRows(cols, []driver.Value{int64(7), "gym", nil})
+	byID.FindOne(&r)
+	byID.FindOne(&r)
+	if _, err := pool.GetModel().Query("UPDATE room SET name = ? WHERE id = ?", "gym", 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := byID.FindOne(&r); err != nil || r.Name != "gym" {
+		t.Errorf("FindOne after a raw UPDATE = %+v, %v", r, err)
+	}
+
+	// a write to a table read in a subquery, however it is spelled,
+	// invalidates the entry
+	vip := pool.GetModel().SetTable("user").Fileds("id").Where("vip = ?", 1)
+	byOwner := rooms.Cached(0).Where("owner IN ?", vip)
+	owned := "SELECT * FROM `room` WHERE owner IN (SELECT `id` FROM `user` WHERE vip = ?)"
+	fake.Expect(owned).WithArgs(1).Times(2).WillReturnRows(cols, []driver.Value{int64(7), "gym", nil})
+	fake.Expect("UPDATE `app`.`User` SET `vip` = ? WHERE id = ?").WithArgs(0, 3).WillReturnResult(0, 1)
+	byOwner.Find(&rs)
+	byOwner.Find(&rs)
+	if _, err := pool.GetModel().SetTable("app.User").Where("id = ?", 3).Update(map[string]interface{}{"vip": 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := byOwner.Find(&rs); err != nil || len(rs) != 1 {
+		t.Errorf("Find after an UPDATE of a subquery table = %+v, %v", rs, err)
+	}
+
+	// uncached reads always run
+	fake.Expect("SELECT COUNT(*) FROM `room`").Times(2).WillReturnRows([]string{"COUNT(*)"}, []driver.Value{int64(3)})
+	for i := 0; i < 2; i++ {
+		if n, err := rooms.Count(); err != nil || n != 3 {
+			t.Errorf("Count = %d, %v", n, err)
+		}
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSQLTables(t *testing.T) {
+	for query, want := range map[string][]string{
+		"SELECT * FROM `room` WHERE id = ?":                                                       {"room"},
+		"SELECT * FROM `app`.`Room` r INNER JOIN `user` ON r.owner = user.id":                     {"room", "user"},
+		"SELECT * FROM a, b AS x, `c` y WHERE a.id = ?":                                           {"a", "b", "c"},
+		"SELECT * FROM room WHERE owner IN (SELECT id FROM app.user WHERE name = 'FROM x')":       {"room", "user"},
+		"SELECT * FROM (SELECT id FROM room) r":                                                   {"room"},
+		"UPDATE `room` SET `n` = n + 1 WHERE EXISTS (SELECT 1 FROM log WHERE log.room = room.id)": {"room", "log"},
+		"INSERT INTO `log` (`msg`) VALUES (?)":                                                    {"log"},
+		"LOAD DATA LOCAL INFILE 'Reader::x' IGNORE INTO TABLE `room` (`id`)":                      {"room"},
+	} {
+		if got, ok := sqlTables(query); !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("sqlTables(%q) = %q, %v, want %q", query, got, ok, want)
+		}
+	}
+	for _, query := range []string{
+		"SELECT a INTO @a FROM t",
+		"SELECT * FROM t FOR UPDATE",
+		"SELECT TRIM(LEADING 'x' FROM 'xa')",
+	} {
+		if got, ok := sqlTables(query); ok {
+			t.Errorf("sqlTables(%q) = %q, want unknown", query, got)
+		}
+	}
+}
+
+func TestDeepCopy(t *testing.T) {
+	s := "x"
+	v := map[string]interface{}{"p": &s, "b": []byte("ab"), "t": time.Unix(1, 0)}
+	c := deepCopy(reflect.ValueOf(v)).Interface().(map[string]interface{})
+	*c["p"].(*string) = "y"
+	c["b"].([]byte)[0] = 'z'
+	if s != "x" || string(v["b"].([]byte)) != "ab" || !c["t"].(time.Time).Equal(time.Unix(1, 0)) {
+		t.Errorf("copy shares memory: %v", v)
+	}
+}
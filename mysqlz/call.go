@@ -0,0 +1,194 @@
+package mysqlz
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Param is an argument of a stored procedure, see Call.
+type Param struct {
+	value interface{}
+	dest  interface{} // for OUT and INOUT
+	in    bool
+}
+
+// In passes v to an IN parameter.
+func In(v interface{}) Param {
+	return Param{value: v, in: true}
+}
+
+// Out reads an OUT parameter into dest, a pointer Scan accepts.
+func Out(dest interface{}) Param {
+	return Param{dest: dest}
+}
+
+// InOut passes the value dest points to and reads the parameter back into it.
+func InOut(dest interface{}) Param {
+	return Param{dest: dest, in: true}
+}
+
+// callSQL returns the statements of a call: setup assigns the session
+// variables of OUT and INOUT parameters, with setupArgs[i] the args of
+// setup[i], call runs the procedure and fetch reads the variables back (""
+// without any).
+func callSQL(proc string, params []Param) (setup []string, setupArgs [][]interface{}, call string, callArgs []interface{}, fetch string, err error) {
+	marks := make([]string, len(params))
+	var vars []string
+	for i, p := range params {
+		if p.dest == nil {
+			marks[i] = "?"
+			callArgs = append(callArgs, p.value)
+			continue
+		}
+		if v := reflect.ValueOf(p.dest); v.Kind() != reflect.Ptr || v.IsNil() {
+			return nil, nil, "", nil, "", fmt.Errorf("mysqlz: param %d of %s needs a non-nil pointer, got %T", i+1, proc, p.dest)
+		}
+		name := fmt.Sprintf("@_litego_p%d", i+1)
+		marks[i] = name
+		vars = append(vars, name)
+		if p.in {
+			setup = append(setup, "SET "+name+" = ?")
+			setupArgs = append(setupArgs, []interface{}{reflect.ValueOf(p.dest).Elem().Interface()})
+		} else {
+			setup = append(setup, "SET "+name+" = NULL")
+			setupArgs = append(setupArgs, nil)
+		}
+	}
+	call = fmt.Sprintf("CALL %s(%s)", quoteIdent(proc), strings.Join(marks, ", "))
+	if len(vars) > 0 {
+		fetch = "SELECT " + strings.Join(vars, ", ")
+	}
+	return setup, setupArgs, call, callArgs, fetch, nil
+}
+
+// Call runs the stored procedure proc on one connection and returns every
+// result set it selects, each like FindAll. OUT and INOUT parameters are
+// bound to session variables and read back once the call is done:
+//
+//	var count int
+//	_, err := pool.Call(ctx, "follow_liveroom_num", In(id), Out(&count))
+func (m *MysqlConnPool) Call(ctx context.Context, proc string, params ...Param) ([]map[int]map[string]string, error) {
+	if m.dbconnpool == nil {
+		return nil, ErrNotConnected
+	}
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+	conn, err := m.dbconnpool.Conn(ctx)
+	if err != nil {
+		return nil, wrapError("CALL "+proc, nil, err)
+	}
+	defer conn.Close()
+	run := &Model{run: conn, hooks: m.hooks}
+	if m.cache != nil {
+		run.invalidate = m.cache.invalidate
+	}
+	return call(ctx, run, proc, params)
+}
+
+// Call runs the stored procedure proc inside the transaction, see
+// MysqlConnPool.Call.
+func (t *TxModel) Call(proc string, params ...Param) ([]map[int]map[string]string, error) {
+	ctx := t.ctx
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+	return call(ctx, &Model{run: t, hooks: t.hooks, invalidate: t.writes.add}, proc, params)
+}
+
+// Call runs the stored procedure on the primary, see MysqlConnPool.Call.
+func (c *Cluster) Call(ctx context.Context, proc string, params ...Param) ([]map[int]map[string]string, error) {
+	return c.primary.Call(ctx, proc, params...)
+}
+
+// call runs the statements of a call through m, firing its hooks.
+func call(ctx context.Context, m *Model, proc string, params []Param) ([]map[int]map[string]string, error) {
+	setup, setupArgs, query, args, fetch, err := callSQL(proc, params)
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range setup {
+		if _, err := m.exec(ctx, s, setupArgs[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, done, err := m.query(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	sets, err := resultSets(rows)
+	n := 0
+	for _, set := range sets {
+		n += len(set)
+	}
+	done(int64(n), err)
+	if err != nil {
+		return nil, wrapError(query, args, err)
+	}
+	m.writtenAny()
+
+	if fetch == "" {
+		return sets, nil
+	}
+	dests := make([]interface{}, 0, len(setup))
+	for _, p := range params {
+		if p.dest != nil {
+			dests = append(dests, p.dest)
+		}
+	}
+	rows, done, err = m.query(ctx, fetch, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = scanVars(rows, dests)
+	done(1, err)
+	if err != nil {
+		return nil, wrapError(fetch, nil, err)
+	}
+	return sets, nil
+}
+
+// scanVars reads and closes the one row of the session variables.
+func scanVars(rows *sql.Rows, dests []interface{}) error {
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err := rows.Scan(dests...); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// resultSets reads and closes every result set of rows that has columns.
+func resultSets(rows *sql.Rows) ([]map[int]map[string]string, error) {
+	defer rows.Close()
+	var sets []map[int]map[string]string
+	for {
+		cols, err := rows.Columns()
+		if err != nil {
+			return nil, err
+		}
+		if len(cols) > 0 {
+			set, err := readResult(rows, cols)
+			if err != nil {
+				return nil, err
+			}
+			sets = append(sets, set)
+		}
+		if !rows.NextResultSet() {
+			return sets, rows.Err()
+		}
+	}
+}
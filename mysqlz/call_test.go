@@ -0,0 +1,34 @@
+package mysqlz
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCallSQL(t *testing.T) {
+	var count int
+	total := int64(5)
+	setup, setupArgs, call, args, fetch, err := callSQL("follow_liveroom_num", []Param{In(210150), Out(&count), InOut(&total)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"SET @_litego_p2 = NULL", "SET @_litego_p3 = ?"}; !reflect.DeepEqual(setup, want) {
+		t.Errorf("setup = %q", setup)
+	}
+	if want := [][]interface{}{nil, {int64(5)}}; !reflect.DeepEqual(setupArgs, want) {
+		t.Errorf("setup args = %v", setupArgs)
+	}
+	if call != "CALL `follow_liveroom_num`(?, @_litego_p2, @_litego_p3)" || !reflect.DeepEqual(args, []interface{}{210150}) {
+		t.Errorf("call = %q %v", call, args)
+	}
+	if fetch != "SELECT @_litego_p2, @_litego_p3" {
+		t.Errorf("fetch = %q", fetch)
+	}
+
+	if _, _, _, _, fetch, _ := callSQL("p", []Param{In(1)}); fetch != "" {
+		t.Errorf("fetch without OUT params = %q", fetch)
+	}
+	if _, _, _, _, _, err := callSQL("p", []Param{Out(count)}); err == nil {
+		t.Error("Out with a non-pointer accepted")
+	}
+}
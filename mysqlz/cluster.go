@@ -0,0 +1,187 @@
+package mysqlz
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"litego/logger"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Balance selects the replica serving the next read of a Cluster.
+type Balance int
+
+const (
+	RoundRobin Balance = iota
+	LeastConn          // fewest connections in use
+)
+
+// ClusterOptions configures a Cluster.
+type ClusterOptions struct {
+	Balance        Balance
+	HealthInterval time.Duration // between replica pings, default 5s
+}
+
+// Cluster routes the reads of its Models to healthy replicas and writes
+// and transactions to the primary. A replica failing a health check or a
+// read with a connection error is ejected until a later check succeeds;
+// with no healthy replica reads go to the primary.
+type Cluster struct {
+	primary  *MysqlConnPool
+	replicas []*replica
+	opts     ClusterOptions
+	next     uint32
+	stop     chan struct{}
+	done     sync.WaitGroup
+}
+
+type replica struct {
+	pool    *MysqlConnPool
+	healthy int32
+}
+
+// NewCluster builds a cluster of open pools and starts health checking
+// the replicas. Close closes all pools.
+func NewCluster(primary *MysqlConnPool, replicas []*MysqlConnPool, opts ClusterOptions) *Cluster {
+	if opts.HealthInterval <= 0 {
+		opts.HealthInterval = 5 * time.Second
+	}
+	c := &Cluster{primary: primary, opts: opts, stop: make(chan struct{})}
+	for _, p := range replicas {
+		c.replicas = append(c.replicas, &replica{pool: p, healthy: 1})
+	}
+	if len(c.replicas) > 0 {
+		c.done.Add(1)
+		go c.healthLoop()
+	}
+	return c
+}
+
+// NewClusterOptions connects the primary and replicas described by
+// options, see NewMysqlConnPoolOptions.
+func NewClusterOptions(primary Options, replicas []Options, opts ClusterOptions) (*Cluster, error) {
+	pp, err := NewMysqlConnPoolOptions(primary)
+	if err != nil {
+		return nil, err
+	}
+	var pools []*MysqlConnPool
+	for _, o := range replicas {
+		p, err := NewMysqlConnPoolOptions(o)
+		if err != nil {
+			pp.ClosePool()
+			for _, p := range pools {
+				p.ClosePool()
+			}
+			return nil, err
+		}
+		pools = append(pools, p)
+	}
+	return NewCluster(pp, pools, opts), nil
+}
+
+// Primary returns the pool of the primary.
+func (c *Cluster) Primary() *MysqlConnPool {
+	return c.primary
+}
+
+// GetModel returns a Model reading from the replicas, see Model.UsePrimary.
+func (c *Cluster) GetModel() *Model {
+	m := c.primary.GetModel()
+	m.cluster = c
+	return m
+}
+
+// Tx runs fn in a transaction on the primary, see MysqlConnPool.Tx.
+func (c *Cluster) Tx(ctx context.Context, fn func(tx *TxModel) error) error {
+	return c.primary.Tx(ctx, fn)
+}
+
+func (c *Cluster) TxWith(ctx context.Context, opts TxOptions, fn func(tx *TxModel) error) error {
+	return c.primary.TxWith(ctx, opts, fn)
+}
+
+// Close stops the health checks and closes every pool.
+func (c *Cluster) Close() {
+	close(c.stop)
+	c.done.Wait()
+	c.primary.ClosePool()
+	for _, r := range c.replicas {
+		r.pool.ClosePool()
+	}
+}
+
+// pick returns a healthy replica, or nil if there is none.
+func (c *Cluster) pick() *replica {
+	n := len(c.replicas)
+	if c.opts.Balance == LeastConn {
+		var best *replica
+		bestUse := 0
+		for _, r := range c.replicas {
+			if atomic.LoadInt32(&r.healthy) == 0 {
+				continue
+			}
+			if use := r.pool.dbconnpool.Stats().InUse; best == nil || use < bestUse {
+				best, bestUse = r, use
+			}
+		}
+		return best
+	}
+	start := int(atomic.AddUint32(&c.next, 1))
+	for i := 0; i < n; i++ {
+		r := c.replicas[(start+i)%n]
+		if atomic.LoadInt32(&r.healthy) == 1 {
+			return r
+		}
+	}
+	return nil
+}
+
+func (c *Cluster) setHealthy(r *replica, ok bool) {
+	var v int32
+	if ok {
+		v = 1
+	}
+	if atomic.SwapInt32(&r.healthy, v) != v {
+		if ok {
+			logger.Info("mysqlz: replica back in service: ", r.pool.opts)
+		} else {
+			logger.Warn("mysqlz: replica ejected: ", r.pool.opts)
+		}
+	}
+}
+
+// CheckHealth pings every replica once, updating which serve reads.
+func (c *Cluster) CheckHealth() {
+	for _, r := range c.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), c.opts.HealthInterval)
+		err := r.pool.dbconnpool.PingContext(ctx)
+		cancel()
+		c.setHealthy(r, err == nil)
+	}
+}
+
+func (c *Cluster) healthLoop() {
+	defer c.done.Done()
+	t := time.NewTicker(c.opts.HealthInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+			c.CheckHealth()
+		}
+	}
+}
+
+// isConnError reports whether err means the server is unreachable rather
+// than that the statement failed.
+func isConnError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.As(err, &netErr)
+}
@@ -0,0 +1,77 @@
+package mysqlz
+
+import (
+	"database/sql"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// unreachablePool returns a pool whose connections are refused.
+func unreachablePool(t *testing.T) *MysqlConnPool {
+	opts := Options{User: "u", Port: 1, Timeout: time.Second}.withDefaults()
+	dsn, err := opts.DSN()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &MysqlConnPool{opts: opts, dbconnpool: db}
+}
+
+func TestClusterPick(t *testing.T) {
+	c := NewCluster(unreachablePool(t), []*MysqlConnPool{unreachablePool(t), unreachablePool(t), unreachablePool(t)},
+		ClusterOptions{HealthInterval: time.Hour})
+	defer c.Close()
+
+	atomic.StoreInt32(&c.replicas[1].healthy, 0)
+	seen := map[*replica]int{}
+	for i := 0; i < 6; i++ {
+		seen[c.pick()]++
+	}
+	if len(seen) != 2 || seen[c.replicas[0]] == 0 || seen[c.replicas[2]] == 0 {
+		t.Errorf("round robin picked %v", seen)
+	}
+
+	c.opts.Balance = LeastConn
+	if r := c.pick(); r != c.replicas[0] {
+		t.Errorf("least conn picked %v", r)
+	}
+
+	c.CheckHealth()
+	if r := c.pick(); r != nil {
+		t.Errorf("unreachable replica %v still picked", r)
+	}
+}
+
+func TestClusterEjectOnRead(t *testing.T) {
+	c := NewCluster(unreachablePool(t), []*MysqlConnPool{unreachablePool(t)}, ClusterOptions{HealthInterval: time.Hour})
+	defer c.Close()
+
+	if _, err := c.GetModel().SetTable("user").FindAll(); err == nil {
+		t.Fatal("FindAll on unreachable servers succeeded")
+	}
+	if atomic.LoadInt32(&c.replicas[0].healthy) != 0 {
+		t.Error("replica not ejected after a connection error")
+	}
+}
+
+func TestReplicaRouting(t *testing.T) {
+	c := NewCluster(unreachablePool(t), []*MysqlConnPool{unreachablePool(t)}, ClusterOptions{HealthInterval: time.Hour})
+	defer c.Close()
+
+	for query, want := range map[string]bool{
+		"SELECT * FROM t":                         true,
+		"SELECT * FROM t WHERE id = 1 FOR UPDATE": false,
+		"select a from t lock in share mode":      false,
+	} {
+		if got := c.GetModel().replica(query) != nil; got != want {
+			t.Errorf("%q on replica = %v, want %v", query, got, want)
+		}
+	}
+	if c.GetModel().UsePrimary().replica("SELECT 1") != nil {
+		t.Error("UsePrimary read routed to a replica")
+	}
+}
@@ -0,0 +1,185 @@
+package mysqlz
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrNotConnected    = errors.New("mysqlz: mysql not connect")
+	ErrNotFound        = errors.New("mysqlz: no rows found")
+	ErrDuplicateKey    = errors.New("mysqlz: duplicate key")
+	ErrDeadlock        = errors.New("mysqlz: deadlock")
+	ErrLockWaitTimeout = errors.New("mysqlz: lock wait timeout")
+	ErrInvalidCursor   = errors.New("mysqlz: invalid cursor")
+	ErrConflict        = errors.New("mysqlz: row version conflict")
+)
+
+// MySQL server error numbers
+const (
+	erDupEntry        = 1062
+	erLockWaitTimeout = 1205
+	erLockDeadlock    = 1213
+)
+
+// QueryError is returned by every failing Model operation. It carries the
+// statement and the types of its arguments, never their values, so it can
+// be logged safely. errors.Is matches it against ErrDuplicateKey,
+// ErrDeadlock and ErrLockWaitTimeout by the MySQL error number.
+type QueryError struct {
+	SQL      string
+	ArgTypes []string
+	Err      error
+}
+
+func (e *QueryError) Error() string {
+	if len(e.ArgTypes) == 0 {
+		return fmt.Sprintf("mysqlz: %v; sql: %s", e.Err, e.SQL)
+	}
+	return fmt.Sprintf("mysqlz: %v; sql: %s; args: [%s]", e.Err, e.SQL, strings.Join(e.ArgTypes, " "))
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+func (e *QueryError) Is(target error) bool {
+	var myerr *mysql.MySQLError
+	if !errors.As(e.Err, &myerr) {
+		return false
+	}
+	switch target {
+	case ErrDuplicateKey:
+		return myerr.Number == erDupEntry
+	case ErrDeadlock:
+		return myerr.Number == erLockDeadlock
+	case ErrLockWaitTimeout:
+		return myerr.Number == erLockWaitTimeout
+	}
+	return false
+}
+
+// ConflictError is returned by Update with SetVersion when the row was
+// changed or deleted since it was read. It matches ErrConflict.
+type ConflictError struct {
+	Table   string
+	Version interface{}
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("mysqlz: %s changed since version %v", e.Table, e.Version)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+func wrapError(query string, args []interface{}, err error) error {
+	if err == nil {
+		return nil
+	}
+	if qe, ok := err.(*QueryError); ok {
+		return qe
+	}
+	return &QueryError{SQL: strings.Join(strings.Fields(query), " "), ArgTypes: argTypes(args), Err: err}
+}
+
+// argTypes describes args for logs without their values.
+func argTypes(args []interface{}) []string {
+	types := make([]string, len(args))
+	for i, arg := range args {
+		if arg == nil {
+			types[i] = "NULL"
+		} else {
+			types[i] = fmt.Sprintf("%T", arg)
+		}
+	}
+	return types
+}
+
+// convertArgs turns the arguments into values the driver accepts: named
+// types become their base kind, pointers are followed (nil is NULL) and
+// uint64 beyond int64 is sent as text. Maps, structs and other slices than
+// []byte are rejected.
+func convertArgs(args []interface{}) ([]interface{}, error) {
+	out := make([]interface{}, len(args))
+	for i, arg := range args {
+		v, err := convertArg(arg)
+		if err != nil {
+			return args, fmt.Errorf("arg %d: %v", i+1, err)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func convertArg(arg interface{}) (interface{}, error) {
+	switch v := arg.(type) {
+	case nil, string, []byte, bool, int64, float64, time.Time:
+		return v, nil
+	case driver.Valuer:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil, nil
+		}
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case uint:
+		return convertUint(uint64(v)), nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return convertUint(v), nil
+	case float32:
+		return float64(v), nil
+	}
+
+	rv := reflect.ValueOf(arg)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return convertArg(rv.Elem().Interface())
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return convertUint(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Bytes(), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported type %T", arg)
+}
+
+func convertUint(v uint64) interface{} {
+	if v > math.MaxInt64 {
+		return strconv.FormatUint(v, 10)
+	}
+	return int64(v)
+}
@@ -0,0 +1,60 @@
+package mysqlz
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestQueryError(t *testing.T) {
+	err := wrapError("INSERT INTO `user` (`name`)\n  VALUES (?)", []interface{}{"secret", nil},
+		&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+
+	if !errors.Is(err, ErrDuplicateKey) || errors.Is(err, ErrDeadlock) {
+		t.Errorf("errors.Is mismatch for %v", err)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("argument value leaked: %v", err)
+	}
+	if want := "sql: INSERT INTO `user` (`name`) VALUES (?); args: [string NULL]"; !strings.Contains(err.Error(), want) {
+		t.Errorf("err = %v", err)
+	}
+
+	for num, target := range map[uint16]error{1213: ErrDeadlock, 1205: ErrLockWaitTimeout} {
+		if err := wrapError("UPDATE t SET a = 1", nil, &mysql.MySQLError{Number: num}); !errors.Is(err, target) {
+			t.Errorf("error %d does not match %v", num, target)
+		}
+	}
+}
+
+type testStatus int8
+
+func TestConvertArgs(t *testing.T) {
+	now := time.Now()
+	name := "x"
+	var nilName *string
+	args, err := convertArgs([]interface{}{
+		1, int32(2), uint64(math.MaxUint64), uint8(4), float32(1.5), true, now,
+		testStatus(3), &name, nilName, []byte("b"), sql.NullString{String: "n", Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		int64(1), int64(2), "18446744073709551615", int64(4), float64(1.5), true, now,
+		int64(3), "x", nil, []byte("b"), sql.NullString{String: "n", Valid: true},
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("convertArgs = %#v", args)
+	}
+
+	if _, err := convertArgs([]interface{}{1, map[string]int{}}); err == nil || !strings.Contains(err.Error(), "arg 2") {
+		t.Errorf("map argument: err = %v", err)
+	}
+}
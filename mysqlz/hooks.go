@@ -0,0 +1,74 @@
+package mysqlz
+
+import (
+	"context"
+	"litego/logger"
+	"strings"
+	"time"
+)
+
+// QueryEvent describes one statement run by a Model. For a SELECT the
+// event ends when its rows are closed, so Duration includes reading them.
+type QueryEvent struct {
+	SQL      string
+	Args     []interface{}
+	Start    time.Time
+	Duration time.Duration
+	Rows     int64 // rows read or affected, -1 if unknown
+	Err      error
+}
+
+// Hook observes the statements of a pool, e.g. for metrics or tracing.
+// BeforeQuery may return a derived context, which the statement and
+// AfterQuery then use. Hooks are called from many goroutines at once.
+type Hook interface {
+	BeforeQuery(ctx context.Context, e *QueryEvent) context.Context
+	AfterQuery(ctx context.Context, e *QueryEvent)
+}
+
+// AddHook adds h to the Models and transactions created afterwards.
+func (m *MysqlConnPool) AddHook(h Hook) {
+	m.hooks = append(m.hooks[:len(m.hooks):len(m.hooks)], h)
+}
+
+func (m *Model) beforeQuery(ctx context.Context, e *QueryEvent) context.Context {
+	for _, h := range m.hooks {
+		ctx = h.BeforeQuery(ctx, e)
+	}
+	return ctx
+}
+
+func (m *Model) afterQuery(ctx context.Context, e *QueryEvent, err error) {
+	e.Duration = time.Since(e.Start)
+	e.Err = err
+	for i := len(m.hooks) - 1; i >= 0; i-- {
+		m.hooks[i].AfterQuery(ctx, e)
+	}
+}
+
+// SlowQueryLog returns a Hook logging a warning for every statement
+// taking threshold or longer, with the types of its args but not their
+// values.
+func SlowQueryLog(threshold time.Duration) Hook {
+	return slowQueryLog(threshold)
+}
+
+type slowQueryLog time.Duration
+
+func (slowQueryLog) BeforeQuery(ctx context.Context, e *QueryEvent) context.Context {
+	return ctx
+}
+
+func (t slowQueryLog) AfterQuery(ctx context.Context, e *QueryEvent) {
+	if e.Duration < time.Duration(t) {
+		return
+	}
+	sql := strings.Join(strings.Fields(e.SQL), " ")
+	if e.Err != nil {
+		logger.Warnf("mysqlz: slow query %v, rows %d, error %v: %s; args: [%s]",
+			e.Duration, e.Rows, e.Err, sql, strings.Join(argTypes(e.Args), " "))
+		return
+	}
+	logger.Warnf("mysqlz: slow query %v, rows %d: %s; args: [%s]",
+		e.Duration, e.Rows, sql, strings.Join(argTypes(e.Args), " "))
+}
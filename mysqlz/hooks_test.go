@@ -0,0 +1,63 @@
+package mysqlz
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type ctxKey struct{}
+
+// recordHook records the events it sees and checks the context passes
+// from BeforeQuery to AfterQuery.
+type recordHook struct {
+	t      *testing.T
+	events []QueryEvent
+}
+
+func (h *recordHook) BeforeQuery(ctx context.Context, e *QueryEvent) context.Context {
+	return context.WithValue(ctx, ctxKey{}, e.SQL)
+}
+
+func (h *recordHook) AfterQuery(ctx context.Context, e *QueryEvent) {
+	if ctx.Value(ctxKey{}) != e.SQL {
+		h.t.Errorf("AfterQuery did not get the context of BeforeQuery")
+	}
+	h.events = append(h.events, *e)
+}
+
+func TestHooks(t *testing.T) {
+	rec := &recordHook{t: t}
+	hist := NewLatencyHistogram()
+	pool := &MysqlConnPool{}
+	pool.AddHook(rec)
+	pool.AddHook(hist)
+	pool.AddHook(SlowQueryLog(time.Hour))
+
+	m := pool.GetModel()
+	m.run = new(ctxExecutor)
+	users := m.SetTable("user")
+	users.Where("id = ?", 1).Update(map[string]interface{}{"name": "x"})
+	users.Where("id = ?", 2).FindAll()
+	users.Where("id = ?", 3).FindAll()
+
+	if len(rec.events) != 3 {
+		t.Fatalf("got %d events", len(rec.events))
+	}
+	e := rec.events[0]
+	if e.SQL != "UPDATE `user` SET `name` = ? WHERE id = ?" || len(e.Args) != 2 || e.Rows != -1 ||
+		!errors.Is(e.Err, errNoDatabase) || e.Duration <= 0 {
+		t.Errorf("update event = %+v", e)
+	}
+
+	snap := hist.Snapshot()
+	s := snap["SELECT * FROM `user` WHERE id = ?"]
+	if len(snap) != 2 || s.Count != 2 || s.Errors != 2 || s.Buckets[0] != 2 {
+		t.Errorf("histogram = %+v", snap)
+	}
+	hist.Reset()
+	if len(hist.Snapshot()) != 0 {
+		t.Error("Reset kept counts")
+	}
+}
@@ -0,0 +1,461 @@
+// Package migrate applies versioned schema migrations to a MySQL database.
+//
+// A migration is a pair of SQL files named VERSION_NAME.up.sql and
+// VERSION_NAME.down.sql, e.g. 0003_add_user_email.up.sql, or Go functions
+// added with Register. Applied versions are recorded in the table
+// schema_migrations, and a GET_LOCK advisory lock keeps concurrent
+// instances from migrating at the same time.
+//
+// MySQL commits DDL statements implicitly, so a migration failing halfway
+// through its DDL is not rolled back; keep one DDL statement per
+// migration where possible.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"litego/mysqlz"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrLocked     = errors.New("migrate: another migration holds the lock")
+	ErrNoVersion  = errors.New("migrate: unknown version")
+	ErrNoDown     = errors.New("migrate: migration has no down step")
+	ErrDuplicated = errors.New("migrate: duplicate version")
+)
+
+// Func is a migration step written in Go.
+type Func func(ctx context.Context, tx *sql.Tx) error
+
+// Migration is one schema version. Each step is either SQL, possibly
+// several ;-separated statements, or a Func.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	UpFunc   Func
+	DownFunc Func
+}
+
+func (m *Migration) String() string {
+	return fmt.Sprintf("%d_%s", m.Version, m.Name)
+}
+
+func (m *Migration) hasDown() bool {
+	return m.Down != "" || m.DownFunc != nil
+}
+
+// Status is a migration and whether it was applied.
+type Status struct {
+	*Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator runs migrations on a pool.
+type Migrator struct {
+	Table       string        // default "schema_migrations"
+	LockName    string        // default "litego_migrate"
+	LockTimeout time.Duration // to wait for the lock, default 10s
+	// DryRun prints the statements of the pending steps to Out instead of
+	// running them.
+	DryRun bool
+	Out    io.Writer // default os.Stdout
+
+	db         *sql.DB
+	migrations map[int64]*Migration
+}
+
+// New returns a Migrator for the database of pool.
+func New(pool *mysqlz.MysqlConnPool) *Migrator {
+	return NewDB(pool.GetDBConn())
+}
+
+// NewDB returns a Migrator for db.
+func NewDB(db *sql.DB) *Migrator {
+	return &Migrator{
+		Table:       "schema_migrations",
+		LockName:    "litego_migrate",
+		LockTimeout: 10 * time.Second,
+		Out:         os.Stdout,
+		db:          db,
+		migrations:  make(map[int64]*Migration),
+	}
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load adds the migrations of the .sql files in dir of fsys, e.g. an
+// embed.FS; other files are ignored.
+func (m *Migrator) Load(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("migrate: %v", err)
+	}
+	found := make(map[int64]*Migration)
+	for _, e := range entries {
+		match := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("migrate: %s: %v", e.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return fmt.Errorf("migrate: %v", err)
+		}
+		mig := found[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: match[2]}
+			found[version] = mig
+		} else if mig.Name != match[2] {
+			return fmt.Errorf("%w %d: %s and %s", ErrDuplicated, version, mig.Name, match[2])
+		}
+		if match[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+	for _, mig := range found {
+		if mig.Up == "" {
+			return fmt.Errorf("migrate: %s has no up.sql", mig)
+		}
+		if err := m.Add(mig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadDir is Load for a directory on disk.
+func (m *Migrator) LoadDir(dir string) error {
+	return m.Load(os.DirFS(dir), ".")
+}
+
+// Register adds a migration written in Go; down may be nil.
+func (m *Migrator) Register(version int64, name string, up, down Func) error {
+	return m.Add(&Migration{Version: version, Name: name, UpFunc: up, DownFunc: down})
+}
+
+// Add adds mig.
+func (m *Migrator) Add(mig *Migration) error {
+	if old, ok := m.migrations[mig.Version]; ok {
+		return fmt.Errorf("%w %d: %s and %s", ErrDuplicated, mig.Version, old.Name, mig.Name)
+	}
+	m.migrations[mig.Version] = mig
+	return nil
+}
+
+// Migrations returns the known migrations by version.
+func (m *Migrator) Migrations() []*Migration {
+	list := make([]*Migration, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		list = append(list, mig)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+func (m *Migrator) createTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS `"+m.Table+"` ("+
+		"version BIGINT NOT NULL PRIMARY KEY, "+
+		"name VARCHAR(255) NOT NULL, "+
+		"applied_at DATETIME NOT NULL)")
+	return err
+}
+
+// applied returns the applied versions and when they were applied.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM `"+m.Table+"`")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	versions := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			v  int64
+			at mysql.NullTime
+		)
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		versions[v] = at.Time
+	}
+	return versions, rows.Err()
+}
+
+// Status lists every known migration, and applied versions with no
+// migration, by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var list []Status
+	err := m.withConn(ctx, false, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		list = m.status(applied)
+		return nil
+	})
+	return list, err
+}
+
+func (m *Migrator) status(applied map[int64]time.Time) []Status {
+	var list []Status
+	for _, mig := range m.Migrations() {
+		at, ok := applied[mig.Version]
+		list = append(list, Status{Migration: mig, Applied: ok, AppliedAt: at})
+	}
+	for v, at := range applied {
+		if _, ok := m.migrations[v]; !ok {
+			list = append(list, Status{Migration: &Migration{Version: v, Name: "(missing)"}, Applied: true, AppliedAt: at})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+// Up applies every pending migration in version order.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, -1)
+}
+
+// Down reverts the last n applied migrations.
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.withConn(ctx, true, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		plan, err := m.downPlan(applied, n)
+		if err != nil {
+			return err
+		}
+		return m.run(ctx, conn, plan, false)
+	})
+}
+
+// To applies or reverts migrations until version is the last one applied;
+// version 0 reverts all and -1 applies all.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if _, ok := m.migrations[version]; version > 0 && !ok {
+		return fmt.Errorf("%w %d", ErrNoVersion, version)
+	}
+	return m.withConn(ctx, true, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		var down []*Migration
+		for _, mig := range m.Migrations() {
+			if _, ok := applied[mig.Version]; ok && version >= 0 && mig.Version > version {
+				down = append(down, mig)
+			}
+		}
+		if len(down) > 0 {
+			plan, err := m.downPlan(applied, len(down))
+			if err != nil {
+				return err
+			}
+			if err := m.run(ctx, conn, plan, false); err != nil {
+				return err
+			}
+			for _, mig := range plan {
+				delete(applied, mig.Version)
+			}
+		}
+		return m.run(ctx, conn, m.upPlan(applied, version), true)
+	})
+}
+
+func (m *Migrator) upPlan(applied map[int64]time.Time, version int64) []*Migration {
+	var plan []*Migration
+	for _, mig := range m.Migrations() {
+		if _, ok := applied[mig.Version]; !ok && (version < 0 || mig.Version <= version) {
+			plan = append(plan, mig)
+		}
+	}
+	return plan
+}
+
+// downPlan returns the last n applied migrations, newest first.
+func (m *Migrator) downPlan(applied map[int64]time.Time, n int) ([]*Migration, error) {
+	versions := make([]int64, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	if n > len(versions) {
+		n = len(versions)
+	}
+	plan := make([]*Migration, 0, n)
+	for _, v := range versions[:n] {
+		mig, ok := m.migrations[v]
+		if !ok {
+			return nil, fmt.Errorf("%w %d: applied but not loaded", ErrNoVersion, v)
+		}
+		if !mig.hasDown() {
+			return nil, fmt.Errorf("%w: %s", ErrNoDown, mig)
+		}
+		plan = append(plan, mig)
+	}
+	return plan, nil
+}
+
+const erNoSuchTable = 1146
+
+// withConn runs fn on one connection with the applied versions. With lock
+// set it holds the lock and creates the migration table, unless DryRun is
+// set; a missing table means nothing is applied.
+func (m *Migrator) withConn(ctx context.Context, lock bool, fn func(conn *sql.Conn, applied map[int64]time.Time) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	defer conn.Close()
+
+	if lock && !m.DryRun {
+		var got sql.NullInt64
+		err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", m.LockName, int(m.LockTimeout/time.Second)).Scan(&got)
+		if err != nil {
+			return fmt.Errorf("migrate: lock: %w", err)
+		}
+		if got.Int64 != 1 {
+			return ErrLocked
+		}
+		defer conn.ExecContext(context.Background(), "DO RELEASE_LOCK(?)", m.LockName)
+	}
+	if lock && !m.DryRun {
+		if err := m.createTable(ctx, conn); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+	}
+	applied, err := m.applied(ctx, conn)
+	var myerr *mysql.MySQLError
+	if errors.As(err, &myerr) && myerr.Number == erNoSuchTable {
+		applied, err = map[int64]time.Time{}, nil
+	}
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	return fn(conn, applied)
+}
+
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, plan []*Migration, up bool) error {
+	for _, mig := range plan {
+		if err := m.step(ctx, conn, mig, up); err != nil {
+			return fmt.Errorf("migrate: %s: %w", mig, err)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) step(ctx context.Context, conn *sql.Conn, mig *Migration, up bool) error {
+	dir, script, fn := "down", mig.Down, mig.DownFunc
+	record := "DELETE FROM `" + m.Table + "` WHERE version = ?"
+	args := []interface{}{mig.Version}
+	if up {
+		dir, script, fn = "up", mig.Up, mig.UpFunc
+		record = "INSERT INTO `" + m.Table + "` (version, name, applied_at) VALUES (?, ?, UTC_TIMESTAMP())"
+		args = append(args, mig.Name)
+	}
+
+	if m.DryRun {
+		fmt.Fprintf(m.Out, "-- %s %s\n", mig, dir)
+		if fn != nil {
+			fmt.Fprintln(m.Out, "-- (Go function)")
+		}
+		for _, stmt := range SplitStatements(script) {
+			fmt.Fprintf(m.Out, "%s;\n", stmt)
+		}
+		return nil
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if fn != nil {
+		err = fn(ctx, tx)
+	}
+	for _, stmt := range SplitStatements(script) {
+		if err != nil {
+			break
+		}
+		_, err = tx.ExecContext(ctx, stmt)
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, record, args...)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// SplitStatements splits script on the semicolons ending its statements,
+// skipping those in quotes and comments. Empty statements are dropped.
+func SplitStatements(script string) []string {
+	var (
+		stmts []string
+		start int
+		quote byte
+	)
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '#' || (c == '-' && strings.HasPrefix(script[i:], "-- ")):
+			if j := strings.IndexByte(script[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(script)
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			if j := strings.Index(script[i+2:], "*/"); j >= 0 {
+				i += j + 3
+			} else {
+				i = len(script)
+			}
+		case c == ';':
+			stmts = appendStatement(stmts, script[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(script) {
+		stmts = appendStatement(stmts, script[start:])
+	}
+	return stmts
+}
+
+func appendStatement(stmts []string, stmt string) []string {
+	stmt = strings.TrimSpace(stmt)
+	if stmt == "" || isComment(stmt) {
+		return stmts
+	}
+	return append(stmts, stmt)
+}
+
+// isComment reports whether stmt holds nothing but comments.
+func isComment(stmt string) bool {
+	for _, line := range strings.Split(stmt, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
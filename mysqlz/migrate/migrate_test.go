@@ -0,0 +1,86 @@
+package migrate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"db/0001_create_user.up.sql":   {Data: []byte("CREATE TABLE user (id INT)")},
+		"db/0001_create_user.down.sql": {Data: []byte("DROP TABLE user")},
+		"db/0002_add_email.up.sql":     {Data: []byte("ALTER TABLE user ADD email TEXT")},
+		"db/README.md":                 {Data: []byte("notes")},
+	}
+	m := NewDB(nil)
+	if err := m.Load(fsys, "db"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Register(3, "backfill", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, mig := range m.Migrations() {
+		names = append(names, mig.String())
+	}
+	if want := []string{"1_create_user", "2_add_email", "3_backfill"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("migrations = %v", names)
+	}
+	if err := m.Register(2, "other", nil, nil); !errors.Is(err, ErrDuplicated) {
+		t.Errorf("duplicate version: err = %v", err)
+	}
+
+	fsys["db/0004_orphan.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE x")}
+	if err := NewDB(nil).Load(fsys, "db"); err == nil {
+		t.Error("down without up accepted")
+	}
+}
+
+func TestPlans(t *testing.T) {
+	m := NewDB(nil)
+	m.Add(&Migration{Version: 1, Name: "a", Up: "x", Down: "y"})
+	m.Add(&Migration{Version: 2, Name: "b", Up: "x"})
+	m.Add(&Migration{Version: 3, Name: "c", Up: "x", Down: "y"})
+	applied := map[int64]time.Time{1: {}, 3: {}}
+
+	if plan := m.upPlan(applied, -1); len(plan) != 1 || plan[0].Version != 2 {
+		t.Errorf("upPlan = %v", plan)
+	}
+	if plan := m.upPlan(applied, 1); len(plan) != 0 {
+		t.Errorf("upPlan to 1 = %v", plan)
+	}
+	plan, err := m.downPlan(applied, 5)
+	if err != nil || len(plan) != 2 || plan[0].Version != 3 || plan[1].Version != 1 {
+		t.Errorf("downPlan = %v, %v", plan, err)
+	}
+	if _, err := m.downPlan(map[int64]time.Time{2: {}}, 1); !errors.Is(err, ErrNoDown) {
+		t.Errorf("downPlan without down step: err = %v", err)
+	}
+
+	status := m.status(map[int64]time.Time{1: {}, 9: {}})
+	if len(status) != 4 || !status[0].Applied || status[1].Applied || status[3].Name != "(missing)" {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- create the table; really
+CREATE TABLE t (a VARCHAR(10) DEFAULT ';', b TEXT COMMENT "x;y");
+/* a; b */ INSERT INTO t VALUES ('it\'s;', 'x');
+# trailing comment;
+UPDATE t SET a = 'z'`
+	want := []string{
+		"-- create the table; really\nCREATE TABLE t (a VARCHAR(10) DEFAULT ';', b TEXT COMMENT \"x;y\")",
+		"/* a; b */ INSERT INTO t VALUES ('it\\'s;', 'x')",
+		"# trailing comment;\nUPDATE t SET a = 'z'",
+	}
+	if got := SplitStatements(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitStatements =\n%q\nwant\n%q", got, want)
+	}
+	if got := SplitStatements("  ;\n-- only a comment\n"); len(got) != 0 {
+		t.Errorf("SplitStatements of comments = %q", got)
+	}
+}
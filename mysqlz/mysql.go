@@ -1,58 +1,73 @@
 package mysqlz
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
 	"litego/logger"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
 type MysqlConnPool struct {
-	username   string
-	password   string
-	hostname   string
-	port       string
-	database   string
-	charset    string
-	maxopen    int
+	opts       Options
+	timeout    time.Duration
+	hooks      []Hook
+	cache      *QueryCache
 	dbconnpool *sql.DB
 }
 
+// NewMysqlConnPool connects with the defaults of Options for everything
+// but the arguments, see NewMysqlConnPoolOptions.
 func NewMysqlConnPool(username, password, hostname, database string, maxopen int) (*MysqlConnPool, error) {
-	db := &MysqlConnPool{}
+	return NewMysqlConnPoolOptions(Options{
+		User:     username,
+		Password: password,
+		Host:     hostname,
+		Database: database,
+		MaxOpen:  maxopen,
+	})
+}
 
-	db.username = username
-	db.password = password
-	db.hostname = hostname
-	db.port = "3306"
-	db.database = database
-	db.charset = "utf8"
-	if maxopen < 1 {
-		db.maxopen = 5
-	} else {
-		db.maxopen = maxopen
+// NewMysqlConnPoolOptions opens a pool configured by opts and checks the
+// connection.
+func NewMysqlConnPoolOptions(opts Options) (*MysqlConnPool, error) {
+	opts = opts.withDefaults()
+	dsn, err := opts.DSN()
+	if err != nil {
+		return nil, err
 	}
+	logger.Debug("dsn=", opts)
 
-	var err error
-
-	db.dbconnpool, err = sql.Open("mysql",
-		(db.username + ":" + db.password + "@tcp(" + db.hostname + ":" + db.port + ")/" + db.database + "?charset=" + db.charset))
-
-	logger.Debug("dsn=", (db.username + ":" + db.password + "@tcp(" + db.hostname + ":" + db.port + ")/" + db.database + "?charset=" + db.charset))
-
-	db.dbconnpool.SetMaxOpenConns(db.maxopen)
-	db.dbconnpool.SetMaxIdleConns(db.maxopen)
-
-	err = db.dbconnpool.Ping()
+	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		return nil, err
 	}
-	return db, nil
+	db.SetMaxOpenConns(opts.MaxOpen)
+	db.SetMaxIdleConns(opts.MaxIdle)
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("mysqlz: connect %v: %w", opts, err)
+	}
+	return &MysqlConnPool{opts: opts, timeout: opts.QueryTimeout, dbconnpool: db}, nil
+}
+
+// NewMysqlConnPoolFromDB wraps an open db, e.g. the fake database of
+// package mysqlztest. Closing the pool closes db.
+func NewMysqlConnPoolFromDB(db *sql.DB) *MysqlConnPool {
+	return &MysqlConnPool{dbconnpool: db}
+}
+
+// Options returns the options of the pool, with the defaults filled in.
+func (m *MysqlConnPool) Options() Options {
+	return m.opts
 }
 
 func (m *MysqlConnPool) GetDBConn() *sql.DB {
@@ -64,228 +79,449 @@ func (m *MysqlConnPool) ClosePool() {
 	m.dbconnpool = nil
 }
 
+// SetQueryTimeout bounds every statement of the Models and transactions
+// of the pool, including reading its rows; 0 disables the limit.
+func (m *MysqlConnPool) SetQueryTimeout(d time.Duration) {
+	m.timeout = d
+}
+
 func (m *MysqlConnPool) GetModel() *Model {
 	c := new(Model)
 	c.db = m.dbconnpool
+	c.run = m.dbconnpool
+	c.timeout = m.timeout
+	c.hooks = m.hooks
+	if m.cache != nil {
+		c.cache = m.cache
+		c.invalidate = m.cache.invalidate
+	}
 	return c
 }
 
+// executor runs the statements of a Model: the pool, a transaction or one
+// pinned connection.
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Model builds and runs the statements of one table. Its builder methods
+// return a modified copy and leave the receiver alone, so a Model bound to
+// a table can be kept and shared by goroutines:
+//
+//	users := pool.GetModel().SetTable("user")
+//	err := users.Where("id = ?", id).FindOne(&u)
 type Model struct {
-	db         *sql.DB
-	table      string
-	param      []string
-	column     string
-	where      string
-	primarykey string
-	orderby    string
-	limit      string
-	join       string
+	db          *sql.DB
+	run         executor
+	ctx         context.Context
+	timeout     time.Duration
+	cluster     *Cluster
+	hooks       []Hook
+	primary     bool
+	cache       *QueryCache
+	cached      bool
+	cacheTTL    time.Duration
+	invalidate  func(tables []string) // nil for every table
+	table       string
+	primarykey  string
+	distinct    bool
+	param       []string
+	joins       []Expr
+	where       Expr
+	groupby     []string
+	having      Expr
+	orderby     []string
+	limit       []int
+	ignore      bool
+	onDuplicate map[string]interface{}
+	softDelete  string
+	withTrashed bool
+	version     string
+}
+
+// clone returns a copy of m; slices are never appended to in place, so
+// they can be shared.
+func (m *Model) clone() *Model {
+	c := *m
+	c.joins = c.joins[:len(c.joins):len(c.joins)]
+	return &c
 }
 
 func (m *Model) GetDB() *sql.DB {
 	return m.db
 }
 
-func (m *Model) FindAll() map[int]map[string]string {
+// WithContext makes the operations without a ctx argument use ctx, e.g.
+// the context of an http.Request, so they stop when it is cancelled.
+func (m *Model) WithContext(ctx context.Context) *Model {
+	c := m.clone()
+	c.ctx = ctx
+	return c
+}
+
+func (m *Model) context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
 
-	result := make(map[int]map[string]string)
-	if m.db == nil {
-		fmt.Printf("mysql not connect")
-		return result
+// withTimeout applies the query timeout of the pool to ctx.
+func (m *Model) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, m.timeout)
+}
+
+func (m *Model) exec(ctx context.Context, query string, args []interface{}) (sql.Result, error) {
+	if m.run == nil {
+		return nil, ErrNotConnected
+	}
+	args, err := convertArgs(args)
+	if err != nil {
+		return nil, wrapError(query, args, err)
 	}
-	if len(m.param) == 0 {
-		m.column = "*"
-	} else {
-		if len(m.param) == 1 {
-			m.column = m.param[0]
-		} else {
-			m.column = strings.Join(m.param, ",")
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	e := &QueryEvent{SQL: query, Args: args, Start: time.Now(), Rows: -1}
+	ctx = m.beforeQuery(ctx, e)
+	result, err := m.run.ExecContext(ctx, query, args...)
+	if err == nil {
+		if n, err := result.RowsAffected(); err == nil {
+			e.Rows = n
 		}
 	}
+	err = wrapError(query, args, err)
+	m.afterQuery(ctx, e, err)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 
-	query := fmt.Sprintf("Select %v from %v %v %v %v %v", m.column, m.table, m.join, m.where, m.orderby, m.limit)
-	rows, err := m.db.Query(query)
+// query runs a SELECT; done must be called with the number of rows read
+// and the error of reading them once the rows are closed.
+func (m *Model) query(ctx context.Context, query string, args []interface{}) (rows *sql.Rows, done func(n int64, err error), err error) {
+	if m.run == nil {
+		return nil, nil, ErrNotConnected
+	}
+	args, err = convertArgs(args)
 	if err != nil {
-		defer func() {
-			if err := recover(); err != nil {
-				fmt.Printf("SQL syntax errors ")
-			}
-		}()
-		err = errors.New("select sql failure")
+		return nil, nil, wrapError(query, args, err)
+	}
+	ctx, cancel := m.withTimeout(ctx)
+	e := &QueryEvent{SQL: query, Args: args, Start: time.Now(), Rows: -1}
+	ctx = m.beforeQuery(ctx, e)
+	done = func(n int64, err error) {
+		e.Rows = n
+		m.afterQuery(ctx, e, wrapError(query, args, err))
+		cancel()
 	}
-	result = QueryResult(rows)
-	return result
+	if r := m.replica(query); r != nil {
+		rows, err = r.pool.dbconnpool.QueryContext(ctx, query, args...)
+		if err == nil {
+			return rows, done, nil
+		}
+		if ctx.Err() != nil || !isConnError(err) {
+			done(-1, err)
+			return nil, nil, wrapError(query, args, err)
+		}
+		m.cluster.setHealthy(r, false)
+	}
+	rows, err = m.run.QueryContext(ctx, query, args...)
+	if err != nil {
+		done(-1, err)
+		return nil, nil, wrapError(query, args, err)
+	}
+	return rows, done, nil
 }
 
-func (m *Model) FindOne() map[int]map[string]string {
-	empty := make(map[int]map[string]string)
-	if m.db != nil {
-		data := m.Limit(1).FindAll()
-		return data
+var lockingRead = regexp.MustCompile(`(?i)\b(for\s+update|lock\s+in\s+share\s+mode|for\s+share)\b`)
+
+// replica returns the replica to run a read on, or nil for the primary.
+func (m *Model) replica(query string) *replica {
+	if m.cluster == nil || m.primary || lockingRead.MatchString(query) {
+		return nil
 	}
-	fmt.Printf("mysql not connect\r\n")
-	return empty
+	return m.cluster.pick()
 }
 
-func (m *Model) Insert(param map[string]interface{}) (num int, err error) {
-	if m.db == nil {
-		return 0, errors.New("mysql not connect")
-	}
-	var keys []string
-	var values []string
-	if len(m.primarykey) != 0 {
-		delete(param, m.primarykey)
-	}
-	for key, value := range param {
-		keys = append(keys, key)
-		switch value.(type) {
-		case int, int64, int32:
-			values = append(values, strconv.Itoa(value.(int)))
-		case string:
-			values = append(values, value.(string))
-		case float32, float64:
-			values = append(values, strconv.FormatFloat(value.(float64), 'f', -1, 64))
+// UsePrimary sends the reads of a Model from a Cluster to the primary, to
+// see its own writes that the replicas may not have applied yet.
+func (m *Model) UsePrimary() *Model {
+	c := m.clone()
+	c.primary = true
+	return c
+}
 
+// FindAll returns the matching rows as strings, numbered from 1.
+func (m *Model) FindAll() (map[int]map[string]string, error) {
+	return m.FindAllContext(m.context())
+}
+
+func (m *Model) FindAllContext(ctx context.Context) (map[int]map[string]string, error) {
+	query, args := m.ToSQL()
+	var result map[int]map[string]string
+	err := m.cachedRead(ctx, query, args, &result, func(ctx context.Context, dest interface{}) error {
+		rows, done, err := m.query(ctx, query, args)
+		if err != nil {
+			return err
+		}
+		r, err := QueryResult(rows)
+		done(int64(len(r)), err)
+		if err != nil {
+			return wrapError(query, args, err)
 		}
+		*dest.(*map[int]map[string]string) = r
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Find scans every matching row into dest, a pointer to a slice of structs,
+// struct pointers or, for a single column, plain values.
+func (m *Model) Find(dest interface{}) error {
+	return m.FindContext(m.context(), dest)
+}
+
+func (m *Model) FindContext(ctx context.Context, dest interface{}) error {
+	query, args := m.ToSQL()
+	return m.cachedRead(ctx, query, args, dest, func(ctx context.Context, dest interface{}) error {
+		rows, done, err := m.query(ctx, query, args)
+		if err != nil {
+			return err
+		}
+		n, err := scanAll(rows, dest)
+		rows.Close()
+		done(int64(n), err)
+		return wrapError(query, args, err)
+	})
+}
+
+// FindOne scans the first matching row into dest, a pointer to a struct or
+// to a single column value. It returns ErrNotFound if nothing matches.
+func (m *Model) FindOne(dest interface{}) error {
+	return m.FindOneContext(m.context(), dest)
+}
+
+func (m *Model) FindOneContext(ctx context.Context, dest interface{}) error {
+	one := m.Limit(1)
+	query, args := one.ToSQL()
+	return m.cachedRead(ctx, query, args, dest, func(ctx context.Context, dest interface{}) error {
+		it, err := one.IterContext(ctx)
+		if err != nil {
+			return err
+		}
+		defer it.Close()
+		if !it.Next() {
+			if err := it.Err(); err != nil {
+				return err
+			}
+			return ErrNotFound
+		}
+		if err := it.Scan(dest); err != nil {
+			return err
+		}
+		return it.Close()
+	})
+}
+
+// Iter runs the query and returns an Iterator over its rows.
+func (m *Model) Iter() (*Iterator, error) {
+	return m.IterContext(m.context())
+}
 
+func (m *Model) IterContext(ctx context.Context) (*Iterator, error) {
+	query, args := m.ToSQL()
+	rows, done, err := m.query(ctx, query, args)
+	if err != nil {
+		return nil, err
 	}
-	fileValue := "'" + strings.Join(values, "','") + "'"
-	fileds := "`" + strings.Join(keys, "`,`") + "`"
-	sql := fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v)", m.table, fileds, fileValue)
-	result, err := m.db.Exec(sql)
+	cols, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		done(0, err)
+		return nil, wrapError(query, args, err)
+	}
+	return &Iterator{rows: rows, cols: cols, done: done}, nil
+}
+
+// Insert adds one row and returns its auto increment id. A duplicate key
+// fails with an error matching ErrDuplicateKey.
+func (m *Model) Insert(param map[string]interface{}) (num int, err error) {
+	return m.InsertContext(m.context(), param)
+}
+
+func (m *Model) InsertContext(ctx context.Context, param map[string]interface{}) (num int, err error) {
+	sql, args := m.insertSQL(param)
+	result, err := m.exec(ctx, sql, args)
 	if err != nil {
-		defer func() {
-			if err := recover(); err != nil {
-				fmt.Printf("SQL syntax errors ")
-			}
-		}()
-		err = errors.New("inster sql failure")
 		return 0, err
 	}
+	m.written(sql)
 	i, err := result.LastInsertId()
-	s, _ := strconv.Atoi(strconv.FormatInt(i, 10))
 	if err != nil {
-		err = errors.New("insert failure")
+		return 0, wrapError(sql, args, err)
 	}
-	return s, err
-
+	return int(i), nil
 }
 
-func (m *Model) Fileds(param ...string) *Model {
-	m.param = param
-	return m
+// InsertBatch adds rows with one multi-row INSERT and returns the number of
+// rows affected. Columns missing from a row get their default value.
+func (m *Model) InsertBatch(rows []map[string]interface{}) (num int, err error) {
+	return m.InsertBatchContext(m.context(), rows)
 }
 
-func (m *Model) Update(param map[string]interface{}) (num int, err error) {
-	if m.db == nil {
-		return 0, errors.New("mysql not connect")
-	}
-	var setValue []string
-	for key, value := range param {
-		switch value.(type) {
-		case int, int64, int32:
-			set := fmt.Sprintf("%v = %v", key, value.(int))
-			setValue = append(setValue, set)
-		case string:
-			set := fmt.Sprintf("%v = '%v'", key, value.(string))
-			setValue = append(setValue, set)
-		case float32, float64:
-			set := fmt.Sprintf("%v = '%v'", key, strconv.FormatFloat(value.(float64), 'f', -1, 64))
-			setValue = append(setValue, set)
-		}
-
+func (m *Model) InsertBatchContext(ctx context.Context, rows []map[string]interface{}) (num int, err error) {
+	if len(rows) == 0 {
+		return 0, nil
 	}
-	setData := strings.Join(setValue, ",")
-	sql := fmt.Sprintf("UPDATE %v SET %v %v", m.table, setData, m.where)
-	result, err := m.db.Exec(sql)
+	sql, args := m.insertSQL(rows...)
+	return m.affected(ctx, sql, args)
+}
+
+// affected runs an exec statement and returns its affected row count.
+func (m *Model) affected(ctx context.Context, query string, args []interface{}) (int, error) {
+	result, err := m.exec(ctx, query, args)
 	if err != nil {
-		defer func() {
-			if err := recover(); err != nil {
-				fmt.Printf("SQL syntax errors ")
-			}
-		}()
-		err = errors.New("update sql failure")
 		return 0, err
 	}
+	m.written(query)
 	i, err := result.RowsAffected()
 	if err != nil {
-		err = errors.New("update failure")
-		return 0, err
+		return 0, wrapError(query, args, err)
 	}
-	s, _ := strconv.Atoi(strconv.FormatInt(i, 10))
+	return int(i), nil
+}
 
-	return s, err
+func (m *Model) Fileds(param ...string) *Model {
+	c := m.clone()
+	c.param = append([]string(nil), param...)
+	return c
 }
 
-func (m *Model) Delete(param string) (num int, err error) {
-	if m.db == nil {
-		return 0, errors.New("mysql not connect")
-	}
-	h := m.Where(param).FindOne()
-	if len(h) == 0 {
-		return 0, errors.New("no Value")
+// Update sets the columns of param on the rows matching Where and returns
+// the number of rows changed. With SetVersion it fails with a
+// ConflictError if no row has the version in param.
+func (m *Model) Update(param map[string]interface{}) (num int, err error) {
+	return m.UpdateContext(m.context(), param)
+}
+
+func (m *Model) UpdateContext(ctx context.Context, param map[string]interface{}) (num int, err error) {
+	if m.version == "" {
+		sql, args := m.updateSQL(param)
+		return m.affected(ctx, sql, args)
 	}
-	sql := fmt.Sprintf("DELETE FROM %v WHERE %v", m.table, param)
-	result, err := m.db.Exec(sql)
+	u, set, err := m.versioned(param)
 	if err != nil {
-		defer func() {
-			if err := recover(); err != nil {
-				fmt.Printf("SQL syntax errors ")
-			}
-		}()
-		err = errors.New("delete sql failure")
 		return 0, err
 	}
-	i, err := result.RowsAffected()
-	s, _ := strconv.Atoi(strconv.FormatInt(i, 10))
-	if i == 0 {
-		err = errors.New("delete failure")
+	sql, args := u.updateSQL(set)
+	num, err = u.affected(ctx, sql, args)
+	if err == nil && num == 0 {
+		return 0, &ConflictError{Table: m.table, Version: param[m.version]}
 	}
+	return num, err
+}
 
-	return s, err
+// Delete removes the rows matching where, which takes ? placeholders like
+// Where, or with SetSoftDelete marks them deleted. It returns ErrNotFound
+// if no row matched. An empty where is an error rather than every row.
+func (m *Model) Delete(where string, args ...interface{}) (num int, err error) {
+	return m.DeleteContext(m.context(), where, args...)
 }
 
-func (m *Model) Query(sql string) interface{} {
-	if m.db == nil {
-		return errors.New("mysql not connect")
+func (m *Model) DeleteContext(ctx context.Context, where string, args ...interface{}) (num int, err error) {
+	if strings.TrimSpace(where) == "" {
+		return 0, fmt.Errorf("mysqlz: Delete from %s needs a condition", m.table)
 	}
+	d := m.Where(where, args...)
+	sql, args := d.deleteSQL()
+	if d.softDelete != "" {
+		sql, args = d.updateSQL(map[string]interface{}{d.softDelete: Raw("NOW()")})
+	}
+	num, err = d.affected(ctx, sql, args)
+	if err == nil && num == 0 {
+		return 0, ErrNotFound
+	}
+	return num, err
+}
+
+// Query runs sql with its ? placeholders bound to args, see Where. A SELECT
+// returns its rows like FindAll, UPDATE and DELETE the affected row count
+// and INSERT the last insert id, both as strings; anything else returns the
+// sql.Result.
+func (m *Model) Query(sql string, args ...interface{}) (interface{}, error) {
+	return m.QueryContext(m.context(), sql, args...)
+}
+
+func (m *Model) QueryContext(ctx context.Context, sql string, args ...interface{}) (interface{}, error) {
 	var query = strings.TrimSpace(sql)
+	query, args = expandArgs(query, args)
 	s, err := regexp.MatchString(`(?i)^select`, query)
 	if err == nil && s == true {
-		result, _ := m.db.Query(sql)
-		c := QueryResult(result)
-		return c
+		rows, done, err := m.query(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+		c, err := QueryResult(rows)
+		done(int64(len(c)), err)
+		if err != nil {
+			return nil, wrapError(query, args, err)
+		}
+		return c, nil
 	}
 	exec, err := regexp.MatchString(`(?i)^(update|delete)`, query)
 	if err == nil && exec == true {
-		m_exec, err := m.db.Exec(query)
+		m_exec, err := m.exec(ctx, query, args)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		m.writtenAny()
 		num, _ := m_exec.RowsAffected()
 		id := strconv.FormatInt(num, 10)
-		return id
+		return id, nil
 	}
 
 	insert, err := regexp.MatchString(`(?i)^insert`, query)
 	if err == nil && insert == true {
-		m_exec, err := m.db.Exec(query)
+		m_exec, err := m.exec(ctx, query, args)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		m.writtenAny()
 		num, _ := m_exec.LastInsertId()
 		id := strconv.FormatInt(num, 10)
-		return id
+		return id, nil
+	}
+	result, err := m.exec(ctx, query, args)
+	if err != nil {
+		return nil, err
 	}
-	result, _ := m.db.Exec(query)
+	m.writtenAny()
+	return result, nil
+}
 
-	return result
+// QueryResult reads and closes rows, returning them as strings numbered
+// from 1. NULL reads as "".
+func QueryResult(rows *sql.Rows) (map[int]map[string]string, error) {
+	defer rows.Close()
 
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	return readResult(rows, columns)
 }
 
-func QueryResult(rows *sql.Rows) map[int]map[string]string {
+// readResult reads the rows of the current result set.
+func readResult(rows *sql.Rows, columns []string) (map[int]map[string]string, error) {
 	var result = make(map[int]map[string]string)
-	columns, _ := rows.Columns()
 	values := make([]sql.RawBytes, len(columns))
 	scanargs := make([]interface{}, len(values))
 	for i := range values {
@@ -295,10 +531,8 @@ func QueryResult(rows *sql.Rows) map[int]map[string]string {
 	var n = 1
 	for rows.Next() {
 		result[n] = make(map[string]string)
-		err := rows.Scan(scanargs...)
-
-		if err != nil {
-			fmt.Println(err)
+		if err := rows.Scan(scanargs...); err != nil {
+			return nil, err
 		}
 
 		for i, v := range values {
@@ -306,57 +540,108 @@ func QueryResult(rows *sql.Rows) map[int]map[string]string {
 		}
 		n++
 	}
-	return result
+	return result, rows.Err()
 }
 
 func (m *Model) SetTable(table string) *Model {
-	m.table = table
-	return m
+	c := m.clone()
+	c.table = table
+	return c
 }
 
-func (m *Model) Where(param string) *Model {
-	m.where = fmt.Sprintf(" where %v", param)
-	return m
+// Where sets the condition; each ? in param is bound to the next arg, a
+// slice arg expands to a list and a *Model to a subquery, e.g.
+// Where("uid = ? AND status IN (?)", uid, statuses). Use WhereExpr to
+// build the condition from And and Or groups.
+func (m *Model) Where(param string, args ...interface{}) *Model {
+	return m.WhereExpr(Raw(param, args...))
+}
+
+// WhereExpr sets the condition to e.
+func (m *Model) WhereExpr(e Expr) *Model {
+	c := m.clone()
+	c.where = e
+	return c
 }
 
 func (m *Model) SetPrimaryKey(key string) *Model {
-	m.primarykey = key
-	return m
+	c := m.clone()
+	c.primarykey = key
+	return c
 }
 
-func (m *Model) OrderBy(param string) *Model {
-	m.orderby = fmt.Sprintf("ORDER BY %v", param)
-	return m
+// Distinct selects only distinct rows.
+func (m *Model) Distinct() *Model {
+	c := m.clone()
+	c.distinct = true
+	return c
 }
 
+// GroupBy sets the GROUP BY columns.
+func (m *Model) GroupBy(cols ...string) *Model {
+	c := m.clone()
+	c.groupby = append([]string(nil), cols...)
+	return c
+}
+
+// Having sets the HAVING condition, with args like Where.
+func (m *Model) Having(param string, args ...interface{}) *Model {
+	c := m.clone()
+	c.having = Raw(param, args...)
+	return c
+}
+
+// OrderBy sets the ORDER BY terms, e.g. OrderBy("score DESC", "id").
+func (m *Model) OrderBy(param ...string) *Model {
+	c := m.clone()
+	c.orderby = append([]string(nil), param...)
+	return c
+}
+
+// Limit takes the row count, or the offset and the row count.
 func (m *Model) Limit(size ...int) *Model {
-	var end int
-	start := size[0]
-	if len(size) > 1 {
-		end = size[1]
-		m.limit = fmt.Sprintf("Limit %d,%d", start, end)
-		return m
-	}
-	m.limit = fmt.Sprintf("Limit %d", start)
-	return m
+	c := m.clone()
+	c.limit = append([]int(nil), size...)
+	return c
 }
 
-func (m *Model) LeftJoin(table, condition string) *Model {
-	m.join = fmt.Sprintf("LEFT JOIN %v ON %v", table, condition)
-	return m
+// LeftJoin adds a LEFT JOIN; condition takes args like Where. Joins are
+// emitted in the order they are added.
+func (m *Model) LeftJoin(table, condition string, args ...interface{}) *Model {
+	return m.addJoin("LEFT JOIN", table, condition, args)
 }
 
-func (m *Model) RightJoin(table, condition string) *Model {
-	m.join = fmt.Sprintf("RIGHT JOIN %v ON %v", table, condition)
-	return m
+func (m *Model) RightJoin(table, condition string, args ...interface{}) *Model {
+	return m.addJoin("RIGHT JOIN", table, condition, args)
 }
 
-func (m *Model) Join(table, condition string) *Model {
-	m.join = fmt.Sprintf("INNER JOIN %v ON %v", table, condition)
-	return m
+func (m *Model) Join(table, condition string, args ...interface{}) *Model {
+	return m.addJoin("INNER JOIN", table, condition, args)
 }
 
-func (m *Model) FullJoin(table, condition string) *Model {
-	m.join = fmt.Sprintf("FULL JOIN %v ON %v", table, condition)
-	return m
+func (m *Model) addJoin(kind, table, condition string, args []interface{}) *Model {
+	on := Raw(condition, args...)
+	c := m.clone()
+	c.joins = append(c.joins, Expr{sql: fmt.Sprintf("%s %s ON %s", kind, quoteIdent(table), on.sql), args: on.args})
+	return c
+}
+
+// Ignore makes Insert and InsertBatch skip rows that hit a duplicate key
+// instead of failing (INSERT IGNORE).
+func (m *Model) Ignore() *Model {
+	c := m.clone()
+	c.ignore = true
+	return c
+}
+
+// OnDuplicateKeyUpdate makes Insert and InsertBatch update the existing row
+// when a row hits a duplicate key, e.g. with
+// map[string]interface{}{"hits": Raw("hits + 1"), "name": Values("name")}.
+func (m *Model) OnDuplicateKeyUpdate(param map[string]interface{}) *Model {
+	c := m.clone()
+	c.onDuplicate = make(map[string]interface{}, len(param))
+	for k, v := range param {
+		c.onDuplicate[k] = v
+	}
+	return c
 }
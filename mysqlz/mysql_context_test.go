@@ -0,0 +1,53 @@
+package mysqlz
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errNoDatabase = errors.New("no database")
+
+// ctxExecutor fails every statement with the error of its context or
+// errNoDatabase.
+type ctxExecutor struct {
+	deadline time.Time
+}
+
+func (e *ctxExecutor) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	e.deadline, _ = ctx.Deadline()
+	return nil, e.err(ctx)
+}
+
+func (e *ctxExecutor) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	e.deadline, _ = ctx.Deadline()
+	return nil, e.err(ctx)
+}
+
+func (e *ctxExecutor) err(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errNoDatabase
+}
+
+func TestModelContext(t *testing.T) {
+	e := new(ctxExecutor)
+	m := &Model{run: e, timeout: time.Minute}
+	m.SetTable("user").Update(map[string]interface{}{"a": 1})
+	if d := time.Until(e.deadline); d <= 0 || d > time.Minute {
+		t.Errorf("deadline in %v, want the query timeout", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := m.WithContext(ctx).FindAll()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("FindAll with cancelled ctx: err = %v", err)
+	}
+	if _, err := m.InsertContext(context.Background(), map[string]interface{}{"a": 1}); !errors.Is(err, errNoDatabase) {
+		t.Errorf("InsertContext: err = %v", err)
+	}
+}
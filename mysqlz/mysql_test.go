@@ -1,26 +1,32 @@
 package mysqlz
 
 import (
-	"fmt"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"litego/mysqlz/mysqlztest"
 	"sync"
 	"testing"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 func TestStoreProceduce(t *testing.T) {
-	connpool, err := NewMysqlConnPool("test", "test", "192.168.115.51", "3306", "test", "utf8", 20)
-	if err != nil {
-		t.Error(err.Error())
-		return
+	fake := mysqlztest.New()
+	connpool := NewMysqlConnPoolFromDB(fake.DB())
+	defer connpool.ClosePool()
+
+	liveroomIds := [4]int64{210150, 210060, 210256, 210123}
+	for _, id := range liveroomIds {
+		fake.Expect("call follow_liveroom_num(?, @count)").WithArgs(id).Times(2)
 	}
+	fake.Expect("select @count").Times(8).WillReturnRows([]string{"@count"}, []driver.Value{int64(3)})
 
 	var wait sync.WaitGroup
-	liveroomIds := [4]int64{210150, 210060, 210256, 210123}
 	for i := 0; i <= 7; i++ {
 		wait.Add(1)
 		go func(n int) {
 			defer wait.Done()
-			t.Log("start n = ", n, "liveroomid = ", liveroomIds[n%4])
-
 			model := connpool.GetModel()
 
 			tx, err := model.GetDB().Begin()
@@ -38,12 +44,147 @@ func TestStoreProceduce(t *testing.T) {
 
 			var count int
 			err = tx.QueryRow("select @count").Scan(&count)
-			if err != nil {
-				t.Error(err.Error())
-				return
+			if err != nil || count != 3 {
+				t.Error(count, err)
 			}
-			t.Log("end n = ", n, "liveroomid = ", liveroomIds[n%4], "count =", count)
 		}(i)
 	}
 	wait.Wait()
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	fake := mysqlztest.New()
+	pool := NewMysqlConnPoolFromDB(fake.DB())
+	rec := &recordHook{t: t}
+	pool.AddHook(rec)
+	fake.Expect("SET @_litego_p2 = NULL").WithArgs()
+	fake.Expect("CALL `follow_liveroom_num`(?, @_litego_p2)").WithArgs(210150).
+		WillReturnRows([]string{"uid"}, []driver.Value{int64(7)}, []driver.Value{int64(8)}).
+		WillReturnRows([]string{"total"}, []driver.Value{"2"})
+	fake.Expect("SELECT @_litego_p2").WillReturnRows([]string{"@_litego_p2"}, []driver.Value{int64(12)})
+
+	var count int
+	sets, err := pool.Call(context.Background(), "follow_liveroom_num", In(210150), Out(&count))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 12 || len(sets) != 2 || sets[0][2]["uid"] != "8" || sets[1][1]["total"] != "2" {
+		t.Errorf("count = %d, sets = %v", count, sets)
+	}
+	if len(rec.events) != 3 || rec.events[1].SQL != "CALL `follow_liveroom_num`(?, @_litego_p2)" ||
+		rec.events[1].Rows != 3 || rec.events[2].SQL != "SELECT @_litego_p2" {
+		t.Errorf("hook events = %+v", rec.events)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+type testUser struct {
+	ID   int64
+	Name string
+}
+
+func TestModelFake(t *testing.T) {
+	fake := mysqlztest.New()
+	users := NewMysqlConnPoolFromDB(fake.DB()).GetModel().SetTable("user").SetPrimaryKey("id")
+
+	fake.Expect("INSERT INTO `user` (`name`) VALUES (?)").WithArgs("ann").WillReturnResult(5, 1)
+	if id, err := users.Insert(map[string]interface{}{"id": 0, "name": "ann"}); err != nil || id != 5 {
+		t.Errorf("Insert = %d, %v", id, err)
+	}
+
+	fake.Expect("SELECT * FROM `user` WHERE id = ? LIMIT 1").WithArgs(5).
+		WillReturnRows([]string{"id", "name"}, []driver.Value{int64(5), "ann"})
+	var u testUser
+	if err := users.Where("id = ?", 5).FindOne(&u); err != nil || u != (testUser{5, "ann"}) {
+		t.Errorf("FindOne = %+v, %v", u, err)
+	}
+
+	fake.Expect("SELECT * FROM `user` WHERE id = ? LIMIT 1").WithArgs(6)
+	if err := users.Where("id = ?", 6).FindOne(&u); err != ErrNotFound {
+		t.Errorf("FindOne of a missing row: err = %v", err)
+	}
+
+	fake.Expect("INSERT INTO `user` (`name`) VALUES (?)").WithArgs("ann").
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	if _, err := users.Insert(map[string]interface{}{"name": "ann"}); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("duplicate Insert: err = %v", err)
+	}
+
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTxFake(t *testing.T) {
+	fake := mysqlztest.New()
+	pool := NewMysqlConnPoolFromDB(fake.DB())
+	update := "UPDATE `account` SET `balance` = balance - ? WHERE id = ?"
+
+	fake.Expect(update).WithArgs(10, 1).WillReturnError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found"})
+	fake.Expect(update).WithArgs(10, 1).WillReturnResult(0, 1)
+	fake.Expect("INSERT INTO `log` (`msg`) VALUES (?)").WithArgs("x").WillReturnError(errors.New("disk full"))
+
+	attempts := 0
+	err := pool.Tx(context.Background(), func(tx *TxModel) error {
+		attempts++
+		accounts := tx.GetModel().SetTable("account")
+		if _, err := accounts.Where("id = ?", 1).Update(map[string]interface{}{"balance": Raw("balance - ?", 10)}); err != nil {
+			return err
+		}
+		inner := tx.Tx(func(tx *TxModel) error {
+			_, err := tx.GetModel().SetTable("log").Insert(map[string]interface{}{"msg": "x"})
+			return err
+		})
+		if inner == nil {
+			t.Error("failing savepoint returned nil")
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Errorf("Tx = %v after %d attempts", err, attempts)
+	}
+
+	var got []string
+	for _, s := range fake.Statements() {
+		got = append(got, s.SQL)
+	}
+	want := []string{"BEGIN", update, "ROLLBACK", "BEGIN", update, "SAVEPOINT litego_sp1",
+		"INSERT INTO `log` (`msg`) VALUES (?)", "ROLLBACK TO SAVEPOINT litego_sp1", "COMMIT"}
+	if len(got) != len(want) {
+		t.Fatalf("statements = %q", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statement %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTxNestedDeadlock(t *testing.T) {
+	fake := mysqlztest.New()
+	pool := NewMysqlConnPoolFromDB(fake.DB())
+	insert := "INSERT INTO `log` (`msg`) VALUES (?)"
+
+	fake.Expect(insert).WithArgs("x").WillReturnError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found"})
+	// the deadlock rolled back the savepoint along with the transaction
+	fake.Expect("ROLLBACK TO SAVEPOINT litego_sp1").
+		WillReturnError(&mysql.MySQLError{Number: 1305, Message: "SAVEPOINT litego_sp1 does not exist"})
+	fake.Expect(insert).WithArgs("x").WillReturnResult(1, 1)
+
+	attempts := 0
+	err := pool.Tx(context.Background(), func(tx *TxModel) error {
+		attempts++
+		return tx.Tx(func(tx *TxModel) error {
+			_, err := tx.GetModel().SetTable("log").Insert(map[string]interface{}{"msg": "x"})
+			return err
+		})
+	})
+	if err != nil || attempts != 2 {
+		t.Errorf("Tx = %v after %d attempts", err, attempts)
+	}
 }
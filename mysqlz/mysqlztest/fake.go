@@ -0,0 +1,409 @@
+// Package mysqlztest provides an in-process database/sql driver standing
+// in for MySQL in tests. It records every statement and answers them from
+// scripted expectations:
+//
+//	fake := mysqlztest.New()
+//	fake.Expect("SELECT * FROM `user` WHERE id = ?").WithArgs(1).
+//		WillReturnRows([]string{"id", "name"}, []driver.Value{int64(1), "ann"})
+//	pool := mysqlz.NewMysqlConnPoolFromDB(fake.DB())
+//	...
+//	if err := fake.ExpectationsWereMet(); err != nil {
+//		t.Error(err)
+//	}
+//
+// Statements are compared with their whitespace collapsed. Transaction
+// control (BEGIN, COMMIT, ROLLBACK and savepoints) is recorded but needs
+// no expectation.
+package mysqlztest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Statement is a statement the fake received.
+type Statement struct {
+	SQL  string
+	Args []driver.Value
+}
+
+// Fake is a scripted database.
+type Fake struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	statements   []Statement
+	db           *sql.DB
+}
+
+// New returns an empty Fake.
+func New() *Fake {
+	f := new(Fake)
+	f.db = sql.OpenDB(connector{f})
+	return f
+}
+
+// DB returns a *sql.DB connected to the fake.
+func (f *Fake) DB() *sql.DB {
+	return f.db
+}
+
+// Statements returns the statements received so far.
+func (f *Fake) Statements() []Statement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Statement(nil), f.statements...)
+}
+
+// Expect adds an expectation for the statement sql.
+func (f *Fake) Expect(sql string) *Expectation {
+	return f.add(&Expectation{sql: normalize(sql), times: 1})
+}
+
+// ExpectRegexp adds an expectation for the statements matching re.
+func (f *Fake) ExpectRegexp(re string) *Expectation {
+	return f.add(&Expectation{re: regexp.MustCompile(re), times: 1})
+}
+
+func (f *Fake) add(e *Expectation) *Expectation {
+	f.mu.Lock()
+	f.expectations = append(f.expectations, e)
+	f.mu.Unlock()
+	return e
+}
+
+// ExpectationsWereMet returns an error naming every expectation not used
+// as often as it should have been.
+func (f *Fake) ExpectationsWereMet() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var missing []string
+	for _, e := range f.expectations {
+		if e.used < e.times {
+			missing = append(missing, fmt.Sprintf("%s (%d of %d)", e, e.used, e.times))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("mysqlztest: unmet expectations: %s", strings.Join(missing, "; "))
+	}
+	return nil
+}
+
+var txControl = regexp.MustCompile(`(?i)^(begin|commit|rollback|savepoint|release savepoint|start transaction)\b`)
+
+// match records the statement and returns the first expectation left
+// that it matches. Like the server, it fails a statement whose args do not
+// match its placeholders.
+func (f *Fake) match(query string, args []driver.Value) (*Expectation, error) {
+	marks := placeholders(query)
+	query = normalize(query)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statements = append(f.statements, Statement{SQL: query, Args: args})
+	if marks != len(args) {
+		return nil, fmt.Errorf("mysqlztest: statement %q has %d placeholders, got %d args", query, marks, len(args))
+	}
+	for _, e := range f.expectations {
+		if e.used < e.times && e.matches(query, args) {
+			e.used++
+			return e, nil
+		}
+	}
+	if txControl.MatchString(query) {
+		return &Expectation{}, nil
+	}
+	return nil, fmt.Errorf("mysqlztest: unexpected statement %q with args %v", query, args)
+}
+
+func normalize(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
+
+// placeholders counts the ? of query outside string literals, quoted
+// identifiers and comments.
+func placeholders(query string) int {
+	n := 0
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '?':
+			n++
+		case c == '\'' || c == '"' || c == '`':
+			for i++; i < len(query) && query[i] != c; i++ {
+				if query[i] == '\\' && c != '`' {
+					i++
+				}
+			}
+		case c == '#' || c == '-' && strings.HasPrefix(query[i:], "--") && (i+2 == len(query) || query[i+2] <= ' '):
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return n
+			}
+			i += end + 3
+		}
+	}
+	return n
+}
+
+// Expectation scripts the answer to a statement.
+type Expectation struct {
+	sql    string
+	re     *regexp.Regexp
+	args   []driver.Value
+	hasArg bool
+	times  int
+	used   int
+
+	sets     []resultSet
+	lastID   int64
+	affected int64
+	err      error
+}
+
+type resultSet struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (e *Expectation) String() string {
+	if e.re != nil {
+		return e.re.String()
+	}
+	return e.sql
+}
+
+// WithArgs makes the expectation match only these args. They are
+// converted like database/sql does, so 1 matches int64(1).
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.hasArg = true
+	e.args = make([]driver.Value, len(args))
+	for i, arg := range args {
+		v, err := driver.DefaultParameterConverter.ConvertValue(arg)
+		if err != nil {
+			panic(fmt.Sprintf("mysqlztest: arg %d: %v", i+1, err))
+		}
+		e.args[i] = v
+	}
+	return e
+}
+
+// Times lets the expectation match n statements.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// WillReturnRows adds a result set; call it again for procedures
+// returning several.
+func (e *Expectation) WillReturnRows(cols []string, rows ...[]driver.Value) *Expectation {
+	e.sets = append(e.sets, resultSet{cols, rows})
+	return e
+}
+
+// WillReturnResult sets the result of an Exec.
+func (e *Expectation) WillReturnResult(lastInsertID, rowsAffected int64) *Expectation {
+	e.lastID, e.affected = lastInsertID, rowsAffected
+	return e
+}
+
+// WillReturnError fails the statement with err.
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+func (e *Expectation) matches(query string, args []driver.Value) bool {
+	if e.re != nil {
+		if !e.re.MatchString(query) {
+			return false
+		}
+	} else if e.sql != query {
+		return false
+	}
+	if !e.hasArg {
+		return true
+	}
+	if len(args) == 0 && len(e.args) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(args, e.args)
+}
+
+type connector struct {
+	f *Fake
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{c.f}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, fmt.Errorf("mysqlztest: use Fake.DB")
+}
+
+type conn struct {
+	f *Fake
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c, query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.f.match("BEGIN", nil); err != nil {
+		return nil, err
+	}
+	return tx{c}, nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	e, err := c.f.match(query, values(args))
+	if err != nil {
+		return nil, err
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return result{e.lastID, e.affected}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	e, err := c.f.match(query, values(args))
+	if err != nil {
+		return nil, err
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	sets := e.sets
+	if len(sets) == 0 {
+		sets = []resultSet{{}}
+	}
+	return &rows{sets: sets}, nil
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	if len(args) == 0 {
+		return nil
+	}
+	vals := make([]driver.Value, len(args))
+	for i, arg := range args {
+		vals[i] = arg.Value
+	}
+	return vals
+}
+
+type stmt struct {
+	c     *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return placeholders(s.query) }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.c.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.c.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	nv := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		nv[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return nv
+}
+
+type tx struct {
+	c *conn
+}
+
+func (t tx) Commit() error {
+	_, err := t.c.f.match("COMMIT", nil)
+	return err
+}
+
+func (t tx) Rollback() error {
+	_, err := t.c.f.match("ROLLBACK", nil)
+	return err
+}
+
+type result struct {
+	lastID, affected int64
+}
+
+func (r result) LastInsertId() (int64, error) { return r.lastID, nil }
+func (r result) RowsAffected() (int64, error) { return r.affected, nil }
+
+type rows struct {
+	sets []resultSet
+	set  int
+	row  int
+}
+
+func (r *rows) Columns() []string {
+	return r.sets[r.set].cols
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	set := r.sets[r.set]
+	if r.row >= len(set.rows) {
+		return io.EOF
+	}
+	copy(dest, set.rows[r.row])
+	r.row++
+	return nil
+}
+
+func (r *rows) HasNextResultSet() bool {
+	return r.set+1 < len(r.sets)
+}
+
+func (r *rows) NextResultSet() error {
+	if !r.HasNextResultSet() {
+		return io.EOF
+	}
+	r.set++
+	r.row = 0
+	return nil
+}
@@ -0,0 +1,31 @@
+package mysqlztest
+
+import "testing"
+
+func TestPlaceholders(t *testing.T) {
+	for query, want := range map[string]int{
+		"SELECT * FROM t WHERE a = ? AND b = ?":            2,
+		"SET @v = NULL":                                    0,
+		`SELECT '?', "?", ` + "`?`" + `, 'it\'s ?' FROM t`: 0,
+		"SELECT 'a''?' FROM t WHERE a = ?":                 1,
+		"SELECT a -- b = ?\nFROM t WHERE c = ?":            1,
+		"SELECT a # ?\n, ? /* ? */ FROM t":                 1,
+		"SELECT a--? FROM t":                               1,
+	} {
+		if got := placeholders(query); got != want {
+			t.Errorf("placeholders(%q) = %d, want %d", query, got, want)
+		}
+	}
+}
+
+func TestArgCount(t *testing.T) {
+	fake := New()
+	fake.Expect("SET @v = NULL")
+	if _, err := fake.DB().Exec("SET @v = NULL", nil); err == nil {
+		t.Error("an arg without a placeholder was accepted")
+	}
+	fake.Expect("SELECT ?").WillReturnRows([]string{"1"})
+	if _, err := fake.DB().Query("SELECT ?"); err == nil {
+		t.Error("a placeholder without an arg was accepted")
+	}
+}
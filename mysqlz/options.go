@@ -0,0 +1,140 @@
+package mysqlz
+
+import (
+	"fmt"
+	"litego/config"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Options configures a MysqlConnPool. The config tags let a section of a
+// config file fill it, see OptionsFromConfig:
+//
+//	[mysql]
+//	user = app
+//	password = ENC(...)
+//	host = 10.0.0.5
+//	database = shop
+//	charset = utf8mb4
+//	tls = skip-verify
+//	parsetime = true
+//	connmaxlifetime = 30m
+type Options struct {
+	User     string `config:"user,required"`
+	Password string `config:"password"`
+	Host     string `config:"host,default=127.0.0.1"`
+	Port     int    `config:"port,default=3306"`
+	Socket   string `config:"socket"` // unix socket path, used instead of Host and Port
+	Database string `config:"database"`
+
+	Charset   string `config:"charset,default=utf8mb4"`
+	Collation string `config:"collation"`
+	// TLS is "true", "skip-verify", "preferred" or a name registered with
+	// mysql.RegisterTLSConfig; empty disables TLS.
+	TLS       string `config:"tls"`
+	ParseTime bool   `config:"parsetime"`
+	Loc       string `config:"loc,default=UTC"` // time zone of DATETIME values
+
+	Timeout      time.Duration `config:"timeout,default=5s"` // dial timeout
+	ReadTimeout  time.Duration `config:"readtimeout"`
+	WriteTimeout time.Duration `config:"writetimeout"`
+	QueryTimeout time.Duration `config:"querytimeout"` // see MysqlConnPool.SetQueryTimeout
+
+	MaxOpen         int           `config:"maxopen,default=5"`
+	MaxIdle         int           `config:"maxidle"`                     // default half of MaxOpen
+	ConnMaxLifetime time.Duration `config:"connmaxlifetime,default=30m"` // <0 for no limit
+	ConnMaxIdleTime time.Duration `config:"connmaxidletime,default=5m"`  // <0 for no limit
+}
+
+// OptionsFromConfig reads Options from section of c.
+func OptionsFromConfig(c config.Configurer, section string) (Options, error) {
+	var opts Options
+	if err := config.Unmarshal(c, section, &opts); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// withDefaults fills the zero fields of options built in code with the
+// defaults of the config tags.
+func (o Options) withDefaults() Options {
+	if o.Host == "" {
+		o.Host = "127.0.0.1"
+	}
+	if o.Port == 0 {
+		o.Port = 3306
+	}
+	if o.Charset == "" {
+		o.Charset = "utf8mb4"
+	}
+	if o.Loc == "" {
+		o.Loc = "UTC"
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 5 * time.Second
+	}
+	if o.MaxOpen < 1 {
+		o.MaxOpen = 5
+	}
+	if o.MaxIdle < 1 || o.MaxIdle > o.MaxOpen {
+		o.MaxIdle = (o.MaxOpen + 1) / 2
+	}
+	if o.ConnMaxLifetime == 0 {
+		o.ConnMaxLifetime = 30 * time.Minute
+	}
+	if o.ConnMaxIdleTime == 0 {
+		o.ConnMaxIdleTime = 5 * time.Minute
+	}
+	return o
+}
+
+// Config returns the driver configuration of the options.
+func (o Options) Config() (*mysql.Config, error) {
+	o = o.withDefaults()
+	cfg := mysql.NewConfig()
+	cfg.User = o.User
+	cfg.Passwd = o.Password
+	if o.Socket != "" {
+		cfg.Net, cfg.Addr = "unix", o.Socket
+	} else {
+		cfg.Net, cfg.Addr = "tcp", net.JoinHostPort(o.Host, strconv.Itoa(o.Port))
+	}
+	cfg.DBName = o.Database
+	cfg.Params = map[string]string{"charset": o.Charset}
+	cfg.Collation = o.Collation
+	cfg.TLSConfig = o.TLS
+	cfg.ParseTime = o.ParseTime
+	loc, err := time.LoadLocation(o.Loc)
+	if err != nil {
+		return nil, fmt.Errorf("mysqlz: loc: %v", err)
+	}
+	cfg.Loc = loc
+	cfg.Timeout = o.Timeout
+	cfg.ReadTimeout = o.ReadTimeout
+	cfg.WriteTimeout = o.WriteTimeout
+	return cfg, nil
+}
+
+// DSN returns the data source name of the options.
+func (o Options) DSN() (string, error) {
+	cfg, err := o.Config()
+	if err != nil {
+		return "", err
+	}
+	return cfg.FormatDSN(), nil
+}
+
+// String returns the DSN with the password masked, for logging.
+func (o Options) String() string {
+	if o.Password != "" {
+		o.Password = "******"
+	}
+	dsn, err := o.DSN()
+	if err != nil {
+		return err.Error()
+	}
+	return dsn
+}
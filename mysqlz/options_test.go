@@ -0,0 +1,64 @@
+package mysqlz
+
+import (
+	"litego/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptionsFromConfig(t *testing.T) {
+	c, err := config.NewConfigData("ini", []byte(`
+[mysql]
+user = app
+password = s3cret
+host = db1
+port = 3307
+database = shop
+tls = skip-verify
+parsetime = true
+loc = Asia/Shanghai
+maxopen = 10
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := OptionsFromConfig(c, "mysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.ConnMaxLifetime != 30*time.Minute || opts.Charset != "utf8mb4" {
+		t.Errorf("defaults not applied: %+v", opts)
+	}
+	dsn, err := opts.DSN()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "app:s3cret@tcp(db1:3307)/shop?loc=Asia%2FShanghai&parseTime=true&timeout=5s&tls=skip-verify&charset=utf8mb4"
+	if dsn != want {
+		t.Errorf("DSN = %s\nwant  %s", dsn, want)
+	}
+	if s := opts.String(); strings.Contains(s, "s3cret") || !strings.HasPrefix(s, "app:******@tcp(db1:3307)/shop") {
+		t.Errorf("String = %s", s)
+	}
+	if opts.withDefaults().MaxIdle != 5 {
+		t.Errorf("MaxIdle = %d", opts.withDefaults().MaxIdle)
+	}
+
+	if _, err := OptionsFromConfig(c, "missing"); err == nil {
+		t.Error("missing user accepted")
+	}
+}
+
+func TestOptionsSocket(t *testing.T) {
+	dsn, err := Options{User: "root", Socket: "/tmp/mysql.sock", Charset: "utf8"}.DSN()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(dsn, "root@unix(/tmp/mysql.sock)/?") || !strings.Contains(dsn, "charset=utf8") {
+		t.Errorf("DSN = %s", dsn)
+	}
+	if _, err := (Options{Loc: "Nowhere/City"}).DSN(); err == nil {
+		t.Error("invalid loc accepted")
+	}
+}
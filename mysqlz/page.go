@@ -0,0 +1,159 @@
+package mysqlz
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// DefaultPageSize is the page size Paginate uses for a size below 1.
+var DefaultPageSize = 20
+
+// Page describes the page a Paginate call returned, ready for an API
+// response.
+type Page struct {
+	Page  int   `json:"page"`
+	Size  int   `json:"size"`
+	Total int64 `json:"total"`
+	Pages int   `json:"pages"`
+}
+
+// countSQL counts the rows ToSQL would return, ignoring order and limit.
+func (m *Model) countSQL() (string, []interface{}) {
+	c := m.clone()
+	c.orderby, c.limit = nil, nil
+	if c.distinct || len(c.groupby) > 0 {
+		query, args := c.ToSQL()
+		return "SELECT COUNT(*) FROM (" + query + ") AS t", args
+	}
+	c.param = []string{"COUNT(*)"}
+	return c.ToSQL()
+}
+
+// Count returns the number of rows matching the joins and condition.
+func (m *Model) Count() (int64, error) {
+	return m.CountContext(m.context())
+}
+
+func (m *Model) CountContext(ctx context.Context) (int64, error) {
+	query, args := m.countSQL()
+	var n int64
+	err := m.cachedRead(ctx, query, args, &n, func(ctx context.Context, dest interface{}) error {
+		rows, done, err := m.query(ctx, query, args)
+		if err != nil {
+			return err
+		}
+		if rows.Next() {
+			err = rows.Scan(dest)
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		rows.Close()
+		done(1, err)
+		return wrapError(query, args, err)
+	})
+	return n, err
+}
+
+// Paginate scans page (from 1) of size rows into dest, see Find, and
+// counts all matching rows. Use an OrderBy for a stable order; for deep
+// pages of large tables After is faster.
+func (m *Model) Paginate(page, size int, dest interface{}) (*Page, error) {
+	return m.PaginateContext(m.context(), page, size, dest)
+}
+
+func (m *Model) PaginateContext(ctx context.Context, page, size int, dest interface{}) (*Page, error) {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = DefaultPageSize
+	}
+	total, err := m.CountContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p := &Page{Page: page, Size: size, Total: total, Pages: int((total + int64(size) - 1) / int64(size))}
+	if int64(page-1)*int64(size) >= total {
+		if dv := reflect.ValueOf(dest); dv.Kind() == reflect.Ptr && dv.Elem().Kind() == reflect.Slice {
+			dv.Elem().SetLen(0)
+		}
+		return p, nil
+	}
+	return p, m.Limit((page-1)*size, size).FindContext(ctx, dest)
+}
+
+// After continues a keyset pagination: it adds col > last to the condition
+// and orders by col, which must be unique and indexed. Unlike a LIMIT
+// offset, the cost does not grow with the page number.
+func (m *Model) After(col string, last interface{}) *Model {
+	return m.keyset(col, ">", last, "")
+}
+
+// Before is After in descending order: col < last, ordered by col DESC.
+func (m *Model) Before(col string, last interface{}) *Model {
+	return m.keyset(col, "<", last, " DESC")
+}
+
+func (m *Model) keyset(col, op string, last interface{}, dir string) *Model {
+	c := m.WhereExpr(And(m.where, Raw(quoteIdent(col)+" "+op+" ?", last)))
+	return c.OrderBy(quoteIdent(col) + dir)
+}
+
+// AfterCursor is After with the value of a cursor from EncodeCursor; an
+// empty cursor starts from the first row.
+func (m *Model) AfterCursor(col, cursor string) (*Model, error) {
+	if cursor == "" {
+		return m.OrderBy(quoteIdent(col)), nil
+	}
+	last, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	return m.After(col, last), nil
+}
+
+// EncodeCursor returns the last key of a page as an opaque string for API
+// responses. Integers, strings, floats and times survive DecodeCursor.
+func EncodeCursor(last interface{}) string {
+	if t, ok := last.(time.Time); ok {
+		last = t.Format("2006-01-02 15:04:05.999999")
+	}
+	if t, ok := last.(*time.Time); ok && t != nil {
+		last = t.Format("2006-01-02 15:04:05.999999")
+	}
+	data, _ := json.Marshal([]interface{}{last})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor returns the key encoded by EncodeCursor.
+func DecodeCursor(cursor string) (interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v []interface{}
+	if err := dec.Decode(&v); err != nil || len(v) != 1 {
+		return nil, ErrInvalidCursor
+	}
+	switch last := v[0].(type) {
+	case json.Number:
+		if i, err := last.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := last.Float64()
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return f, nil
+	case string:
+		return last, nil
+	}
+	return nil, ErrInvalidCursor
+}
@@ -0,0 +1,63 @@
+package mysqlz
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCountSQL(t *testing.T) {
+	m := (&Model{}).SetTable("user").Fileds("id", "name").Join("vip v", "v.uid = user.id").
+		Where("age > ?", 18).OrderBy("id").Limit(40, 20)
+	query, args := m.countSQL()
+	if query != "SELECT COUNT(*) FROM `user` INNER JOIN vip v ON v.uid = user.id WHERE age > ?" || !reflect.DeepEqual(args, []interface{}{18}) {
+		t.Errorf("countSQL = %q %v", query, args)
+	}
+
+	query, _ = m.GroupBy("city").countSQL()
+	if query != "SELECT COUNT(*) FROM (SELECT `id`, `name` FROM `user` INNER JOIN vip v ON v.uid = user.id WHERE age > ? GROUP BY `city`) AS t" {
+		t.Errorf("grouped countSQL = %q", query)
+	}
+}
+
+func TestKeyset(t *testing.T) {
+	m := (&Model{}).SetTable("user").Where("status = ? OR vip = ?", 1, true).Limit(20)
+	query, args := m.After("id", int64(100)).ToSQL()
+	if query != "SELECT * FROM `user` WHERE (status = ? OR vip = ?) AND `id` > ? ORDER BY `id` LIMIT 20" ||
+		!reflect.DeepEqual(args, []interface{}{1, true, int64(100)}) {
+		t.Errorf("After = %q %v", query, args)
+	}
+	query, _ = (&Model{}).SetTable("user").Before("id", 5).ToSQL()
+	if query != "SELECT * FROM `user` WHERE `id` < ? ORDER BY `id` DESC" {
+		t.Errorf("Before = %q", query)
+	}
+
+	first, err := m.AfterCursor("id", "")
+	if query, _ := first.ToSQL(); err != nil || query != "SELECT * FROM `user` WHERE status = ? OR vip = ? ORDER BY `id` LIMIT 20" {
+		t.Errorf("AfterCursor(\"\") = %q, %v", query, err)
+	}
+	if _, err := m.AfterCursor("id", "not a cursor"); err != ErrInvalidCursor {
+		t.Errorf("AfterCursor(garbage): err = %v", err)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	ts := time.Date(2017, 3, 4, 5, 6, 7, 8000, time.UTC)
+	for in, want := range map[interface{}]interface{}{
+		int64(9007199254740993): int64(9007199254740993),
+		42:                      int64(42),
+		1.5:                     1.5,
+		"o'brien":               "o'brien",
+		ts:                      "2017-03-04 05:06:07.000008",
+	} {
+		got, err := DecodeCursor(EncodeCursor(in))
+		if err != nil || got != want {
+			t.Errorf("cursor of %v = %#v, %v", in, got, err)
+		}
+	}
+	for _, bad := range []string{"!!", EncodeCursor(nil), EncodeCursor([]int{1})} {
+		if _, err := DecodeCursor(bad); err != ErrInvalidCursor {
+			t.Errorf("DecodeCursor(%q): err = %v", bad, err)
+		}
+	}
+}
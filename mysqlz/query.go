@@ -0,0 +1,314 @@
+package mysqlz
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var simpleIdent = regexp.MustCompile(`^[A-Za-z0-9_$]+(\.([A-Za-z0-9_$]+|\*))*$`)
+
+// quoteIdent backtick-quotes a table or column name, db.table becomes
+// `db`.`table`. Anything that is not a plain name, like `count(*) AS n` or
+// `user u`, is returned unchanged.
+func quoteIdent(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "*" || !simpleIdent.MatchString(name) {
+		return name
+	}
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if part != "*" {
+			parts[i] = "`" + part + "`"
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// expandArgs rewrites every ? whose arg is a slice into one placeholder per
+// element, so "id IN (?)" with []int{1, 2} becomes "id IN (?, ?)". An empty
+// slice becomes NULL, which matches nothing. []byte is a single value.
+// An Expr or *Model arg is inlined in parentheses, with its own args.
+// Question marks inside quoted strings are left alone.
+func expandArgs(query string, args []interface{}) (string, []interface{}) {
+	var (
+		buf     strings.Builder
+		out     = make([]interface{}, 0, len(args))
+		n       int
+		quote   byte
+		changed bool
+	)
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(query) {
+				buf.WriteByte(c)
+				i++
+				c = query[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?' && n < len(args):
+			arg := args[n]
+			n++
+			if sub, ok := arg.(sqlBuilder); ok {
+				sql, subArgs := sub.ToSQL()
+				buf.WriteString("(" + sql + ")")
+				out = append(out, subArgs...)
+				changed = true
+				continue
+			}
+			rv := reflect.ValueOf(arg)
+			if arg == nil || rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+				out = append(out, arg)
+				break
+			}
+			changed = true
+			if rv.Len() == 0 {
+				buf.WriteString("NULL")
+				continue
+			}
+			for j := 0; j < rv.Len(); j++ {
+				if j > 0 {
+					buf.WriteString(", ")
+				}
+				buf.WriteByte('?')
+				out = append(out, rv.Index(j).Interface())
+			}
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	out = append(out, args[n:]...)
+	if !changed {
+		return query, out
+	}
+	return buf.String(), out
+}
+
+// Expr is a piece of SQL with ? placeholders and their args: a condition
+// for WhereExpr, or a computed value in the maps of Insert and Update.
+type Expr struct {
+	sql  string
+	args []interface{}
+	// group is set on And and Or results, which need parentheses when nested.
+	group bool
+}
+
+// Raw returns sql with its args bound like Where.
+func Raw(sql string, args ...interface{}) Expr {
+	sql, args = expandArgs(strings.TrimSpace(sql), args)
+	return Expr{sql: sql, args: args}
+}
+
+// Values refers to the value a row would have inserted into col, for
+// OnDuplicateKeyUpdate.
+func Values(col string) Expr {
+	return Expr{sql: "VALUES(" + quoteIdent(col) + ")"}
+}
+
+// And joins the non-empty conditions with AND.
+func And(exprs ...Expr) Expr {
+	return joinExprs(" AND ", exprs)
+}
+
+// Or joins the non-empty conditions with OR.
+func Or(exprs ...Expr) Expr {
+	return joinExprs(" OR ", exprs)
+}
+
+// Not negates e.
+func Not(e Expr) Expr {
+	return Expr{sql: "NOT (" + e.sql + ")", args: e.args}
+}
+
+var boolOp = regexp.MustCompile(`(?i)\b(and|or|xor)\b|\|\||&&`)
+
+func joinExprs(op string, exprs []Expr) Expr {
+	var nonEmpty []Expr
+	for _, e := range exprs {
+		if e.sql != "" {
+			nonEmpty = append(nonEmpty, e)
+		}
+	}
+	if len(nonEmpty) < 2 {
+		if len(nonEmpty) == 0 {
+			return Expr{}
+		}
+		return nonEmpty[0]
+	}
+	parts := make([]string, len(nonEmpty))
+	var args []interface{}
+	for i, e := range nonEmpty {
+		if e.group || boolOp.MatchString(e.sql) {
+			parts[i] = "(" + e.sql + ")"
+		} else {
+			parts[i] = e.sql
+		}
+		args = append(args, e.args...)
+	}
+	return Expr{sql: strings.Join(parts, op), args: args, group: true}
+}
+
+// ToSQL returns the SQL and args of e.
+func (e Expr) ToSQL() (string, []interface{}) {
+	return e.sql, e.args
+}
+
+// sqlBuilder is an arg expanded into SQL instead of a placeholder: an Expr
+// or a *Model used as subquery.
+type sqlBuilder interface {
+	ToSQL() (string, []interface{})
+}
+
+func (m *Model) columns() string {
+	if len(m.param) == 0 {
+		return "*"
+	}
+	return quoteIdents(m.param)
+}
+
+func quoteIdents(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteIdent(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// ToSQL returns the SELECT statement of the Model and its args, so queries
+// can be checked without a database. A *Model passed as an arg to Where or
+// Raw becomes a subquery.
+func (m *Model) ToSQL() (string, []interface{}) {
+	var b strings.Builder
+	var args []interface{}
+	b.WriteString("SELECT ")
+	if m.distinct {
+		b.WriteString("DISTINCT ")
+	}
+	b.WriteString(m.columns())
+	b.WriteString(" FROM ")
+	b.WriteString(quoteIdent(m.table))
+	for _, j := range m.joins {
+		b.WriteString(" " + j.sql)
+		args = append(args, j.args...)
+	}
+	args = m.writeWhere(&b, args)
+	if len(m.groupby) > 0 {
+		b.WriteString(" GROUP BY " + quoteIdents(m.groupby))
+	}
+	if m.having.sql != "" {
+		b.WriteString(" HAVING " + m.having.sql)
+		args = append(args, m.having.args...)
+	}
+	if len(m.orderby) > 0 {
+		b.WriteString(" ORDER BY " + strings.Join(m.orderby, ", "))
+	}
+	switch len(m.limit) {
+	case 0:
+	case 1:
+		fmt.Fprintf(&b, " LIMIT %d", m.limit[0])
+	default:
+		fmt.Fprintf(&b, " LIMIT %d, %d", m.limit[0], m.limit[1])
+	}
+	return b.String(), args
+}
+
+func (m *Model) writeWhere(b *strings.Builder, args []interface{}) []interface{} {
+	where := m.condition()
+	if where.sql == "" {
+		return args
+	}
+	b.WriteString(" WHERE " + where.sql)
+	return append(args, where.args...)
+}
+
+// insertSQL builds the INSERT of rows, with columns in sorted order so the
+// statement text is stable. A column missing from a row gets its DEFAULT.
+func (m *Model) insertSQL(rows ...map[string]interface{}) (string, []interface{}) {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, row := range rows {
+		for key := range row {
+			if !seen[key] && (key != m.primarykey || len(m.primarykey) == 0) {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	var args []interface{}
+	b.WriteString("INSERT ")
+	if m.ignore {
+		b.WriteString("IGNORE ")
+	}
+	fmt.Fprintf(&b, "INTO %v (%v) VALUES ", quoteIdent(m.table), quoteIdents(keys))
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		marks := make([]string, len(keys))
+		for j, key := range keys {
+			v, ok := row[key]
+			if !ok {
+				marks[j] = "DEFAULT"
+				continue
+			}
+			marks[j], args = valueSQL(v, args)
+		}
+		b.WriteString("(" + strings.Join(marks, ", ") + ")")
+	}
+	if len(m.onDuplicate) > 0 {
+		sets, setArgs := setSQL(m.onDuplicate)
+		b.WriteString(" ON DUPLICATE KEY UPDATE " + sets)
+		args = append(args, setArgs...)
+	}
+	return b.String(), args
+}
+
+// valueSQL returns the placeholder of v, or the SQL of an Expr.
+func valueSQL(v interface{}, args []interface{}) (string, []interface{}) {
+	if e, ok := v.(Expr); ok {
+		return e.sql, append(args, e.args...)
+	}
+	return "?", append(args, v)
+}
+
+func setSQL(param map[string]interface{}) (string, []interface{}) {
+	keys := make([]string, 0, len(param))
+	for key := range param {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sets := make([]string, len(keys))
+	var args []interface{}
+	for i, key := range keys {
+		var mark string
+		mark, args = valueSQL(param[key], args)
+		sets[i] = quoteIdent(key) + " = " + mark
+	}
+	return strings.Join(sets, ", "), args
+}
+
+func (m *Model) updateSQL(param map[string]interface{}) (string, []interface{}) {
+	var b strings.Builder
+	sets, args := setSQL(param)
+	fmt.Fprintf(&b, "UPDATE %v SET %v", quoteIdent(m.table), sets)
+	args = m.writeWhere(&b, args)
+	return b.String(), args
+}
+
+func (m *Model) deleteSQL() (string, []interface{}) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "DELETE FROM %v", quoteIdent(m.table))
+	args := m.writeWhere(&b, nil)
+	return b.String(), args
+}
@@ -0,0 +1,156 @@
+package mysqlz
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestExpandArgs(t *testing.T) {
+	tests := []struct {
+		query    string
+		args     []interface{}
+		want     string
+		wantArgs []interface{}
+	}{
+		{"uid = ?", []interface{}{1}, "uid = ?", []interface{}{1}},
+		{"uid = ? AND status IN (?)", []interface{}{1, []int{2, 3}}, "uid = ? AND status IN (?, ?)", []interface{}{1, 2, 3}},
+		{"status IN (?)", []interface{}{[]string{}}, "status IN (NULL)", []interface{}{}},
+		{"data = ?", []interface{}{[]byte("x")}, "data = ?", []interface{}{[]byte("x")}},
+		{"name = '?' AND id IN (?)", []interface{}{[]int64{7}}, "name = '?' AND id IN (?)", []interface{}{int64(7)}},
+		{`name = 'it\'s?' AND id IN (?)`, []interface{}{[]int{1, 2}}, `name = 'it\'s?' AND id IN (?, ?)`, []interface{}{1, 2}},
+	}
+	for _, tt := range tests {
+		got, args := expandArgs(tt.query, tt.args)
+		if got != tt.want || !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("expandArgs(%q) = %q %v, want %q %v", tt.query, got, args, tt.want, tt.wantArgs)
+		}
+	}
+}
+
+func TestQuoteIdent(t *testing.T) {
+	for in, want := range map[string]string{
+		"user":          "`user`",
+		"db.user":       "`db`.`user`",
+		"u.*":           "`u`.*",
+		"*":             "*",
+		"count(*) AS n": "count(*) AS n",
+		"user u":        "user u",
+		"`quoted`":      "`quoted`",
+	} {
+		if got := quoteIdent(in); got != want {
+			t.Errorf("quoteIdent(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestModelSQL(t *testing.T) {
+	m := (&Model{}).SetTable("user").SetPrimaryKey("id")
+
+	query, args := m.insertSQL(map[string]interface{}{"id": 1, "name": "o'brien", "age": int64(30)})
+	if query != "INSERT INTO `user` (`age`, `name`) VALUES (?, ?)" || !reflect.DeepEqual(args, []interface{}{int64(30), "o'brien"}) {
+		t.Errorf("insertSQL = %q %v", query, args)
+	}
+
+	m = m.Where("id = ? AND status IN (?)", 5, []int{1, 2})
+	query, args = m.updateSQL(map[string]interface{}{"name": "x", "hits": Raw("hits + ?", 1)})
+	if query != "UPDATE `user` SET `hits` = hits + ?, `name` = ? WHERE id = ? AND status IN (?, ?)" ||
+		!reflect.DeepEqual(args, []interface{}{1, "x", 5, 1, 2}) {
+		t.Errorf("updateSQL = %q %v", query, args)
+	}
+
+	query, args = m.Fileds("id", "name").ToSQL()
+	if query != "SELECT `id`, `name` FROM `user` WHERE id = ? AND status IN (?, ?)" || len(args) != 3 {
+		t.Errorf("ToSQL = %q %v", query, args)
+	}
+
+	query, args = m.deleteSQL()
+	if query != "DELETE FROM `user` WHERE id = ? AND status IN (?, ?)" || len(args) != 3 {
+		t.Errorf("deleteSQL = %q %v", query, args)
+	}
+}
+
+func TestSelectBuilder(t *testing.T) {
+	active := (&Model{}).SetTable("session").Fileds("uid").Where("expires > ?", 100)
+	m := (&Model{}).SetTable("user u").Distinct().Fileds("u.id", "count(o.id) AS n").
+		LeftJoin("orders o", "o.uid = u.id AND o.state = ?", 1).
+		Join("profile p", "p.uid = u.id").
+		WhereExpr(And(
+			Raw("u.status IN (?)", []int{1, 2}),
+			Or(Raw("u.vip = ?", true), Raw("u.score > ?", 10)),
+			Raw("u.id IN ?", active),
+		)).
+		GroupBy("u.id").Having("n > ?", 3).OrderBy("n DESC", "u.id").Limit(20, 10)
+
+	query, args := m.ToSQL()
+	want := "SELECT DISTINCT `u`.`id`, count(o.id) AS n FROM user u" +
+		" LEFT JOIN orders o ON o.uid = u.id AND o.state = ? INNER JOIN profile p ON p.uid = u.id" +
+		" WHERE u.status IN (?, ?) AND (u.vip = ? OR u.score > ?) AND u.id IN (SELECT `uid` FROM `session` WHERE expires > ?)" +
+		" GROUP BY `u`.`id` HAVING n > ? ORDER BY n DESC, u.id LIMIT 20, 10"
+	if query != want {
+		t.Errorf("ToSQL =\n%s\nwant\n%s", query, want)
+	}
+	if wantArgs := []interface{}{1, 1, 2, true, 10, 100, 3}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestConditionGroups(t *testing.T) {
+	tests := []struct {
+		expr Expr
+		want string
+	}{
+		{And(), ""},
+		{And(Raw("a = 1"), Raw("")), "a = 1"},
+		{Or(Raw("a = 1"), And(Raw("b = 2"), Raw("c = 3"))), "a = 1 OR (b = 2 AND c = 3)"},
+		{And(Raw("x BETWEEN 1 AND 2"), Raw("f(y)")), "(x BETWEEN 1 AND 2) AND f(y)"},
+		{Not(Or(Raw("a"), Raw("b"))), "NOT (a OR b)"},
+	}
+	for _, tt := range tests {
+		if got, _ := tt.expr.ToSQL(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestInsertBuilder(t *testing.T) {
+	m := (&Model{}).SetTable("user").Ignore()
+	query, args := m.insertSQL(map[string]interface{}{"name": "a", "age": 1}, map[string]interface{}{"name": "b"})
+	if query != "INSERT IGNORE INTO `user` (`age`, `name`) VALUES (?, ?), (DEFAULT, ?)" ||
+		!reflect.DeepEqual(args, []interface{}{1, "a", "b"}) {
+		t.Errorf("batch insertSQL = %q %v", query, args)
+	}
+
+	m = (&Model{}).SetTable("stat").OnDuplicateKeyUpdate(map[string]interface{}{
+		"hits": Raw("hits + ?", 1),
+		"name": Values("name"),
+	})
+	query, args = m.insertSQL(map[string]interface{}{"id": 7, "name": "x", "hits": 1})
+	if query != "INSERT INTO `stat` (`hits`, `id`, `name`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `hits` = hits + ?, `name` = VALUES(`name`)" ||
+		!reflect.DeepEqual(args, []interface{}{1, 7, "x", 1}) {
+		t.Errorf("upsert insertSQL = %q %v", query, args)
+	}
+}
+
+func TestModelImmutable(t *testing.T) {
+	users := (&Model{}).SetTable("user").Join("profile p", "p.uid = user.id")
+	base, _ := users.ToSQL()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m := users.Where("id = ?", i).Limit(1).LeftJoin("vip v", "v.uid = user.id").OrderBy("id")
+			if query, args := m.ToSQL(); strings.Count(query, "JOIN") != 2 || len(args) != 1 || args[0] != i {
+				t.Errorf("query %d = %q %v", i, query, args)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if query, args := users.ToSQL(); query != base || len(args) != 0 {
+		t.Errorf("shared Model changed to %q %v", query, args)
+	}
+}
@@ -0,0 +1,240 @@
+package mysqlz
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Struct fields map to columns by the tag `db:"col"`; untagged fields use
+// their snake_case name (UserID is user_id) and `db:"-"` skips a field.
+// Embedded structs are flattened. NULL needs a pointer or sql.Null* field,
+// time.Time fields also accept DATETIME text when the DSN lacks parseTime.
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+	fieldCache sync.Map // reflect.Type : map[string][]int
+)
+
+func snakeCase(name string) string {
+	var buf strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				buf.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// structFields returns the field index path of every column name of t.
+func structFields(t reflect.Type) map[string][]int {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.(map[string][]int)
+	}
+	fields := make(map[string][]int)
+	collectFields(t, nil, fields)
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+func collectFields(t reflect.Type, index []int, fields map[string][]int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+		path := append(append([]int(nil), index...), i)
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct && !reflect.PtrTo(f.Type).Implements(scannerType) {
+			collectFields(f.Type, path, fields)
+			continue
+		}
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		name := tag
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			name = tag[:i]
+		}
+		if name == "" {
+			name = snakeCase(f.Name)
+		}
+		name = strings.ToLower(name)
+		if _, dup := fields[name]; !dup || len(path) < len(fields[name]) {
+			fields[name] = path
+		}
+	}
+}
+
+// timeScanner fills a time.Time or *time.Time from DATETIME values, whether
+// the driver returns time.Time or text.
+type timeScanner struct {
+	dst reflect.Value
+}
+
+var timeFormats = []string{"2006-01-02 15:04:05.999999999", "2006-01-02", "15:04:05"}
+
+func (ts timeScanner) Scan(src interface{}) error {
+	var t time.Time
+	switch v := src.(type) {
+	case nil:
+		ts.dst.Set(reflect.Zero(ts.dst.Type()))
+		return nil
+	case time.Time:
+		t = v
+	case []byte, string:
+		s := fmt.Sprintf("%s", v)
+		if s == "0000-00-00" || s == "0000-00-00 00:00:00" {
+			ts.dst.Set(reflect.Zero(ts.dst.Type()))
+			return nil
+		}
+		var err error
+		for _, layout := range timeFormats {
+			if t, err = time.ParseInLocation(layout, s, time.Local); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("mysqlz: cannot parse %q as time", s)
+		}
+	default:
+		return fmt.Errorf("mysqlz: cannot scan %T into time.Time", src)
+	}
+	if ts.dst.Kind() == reflect.Ptr {
+		ts.dst.Set(reflect.ValueOf(&t))
+	} else {
+		ts.dst.Set(reflect.ValueOf(t))
+	}
+	return nil
+}
+
+// scanTargets returns the Scan arguments filling struct v from cols;
+// unknown columns are discarded.
+func scanTargets(v reflect.Value, cols []string, fields map[string][]int) []interface{} {
+	targets := make([]interface{}, len(cols))
+	for i, col := range cols {
+		path, ok := fields[strings.ToLower(col)]
+		if !ok {
+			targets[i] = new(interface{})
+			continue
+		}
+		fv := v
+		for _, x := range path {
+			fv = fv.Field(x)
+		}
+		if fv.Type() == timeType || (fv.Kind() == reflect.Ptr && fv.Type().Elem() == timeType) {
+			targets[i] = timeScanner{fv}
+		} else {
+			targets[i] = fv.Addr().Interface()
+		}
+	}
+	return targets
+}
+
+// scanRow scans the current row into dest, a pointer to a struct or, for a
+// single column, to any value Scan accepts.
+func scanRow(rows *sql.Rows, cols []string, dest reflect.Value) error {
+	v := dest.Elem()
+	if v.Kind() == reflect.Struct && v.Type() != timeType && !dest.Type().Implements(scannerType) {
+		return rows.Scan(scanTargets(v, cols, structFields(v.Type()))...)
+	}
+	if len(cols) != 1 {
+		return fmt.Errorf("mysqlz: cannot scan %d columns into %s", len(cols), v.Type())
+	}
+	if v.Type() == timeType {
+		return rows.Scan(timeScanner{v})
+	}
+	return rows.Scan(dest.Interface())
+}
+
+// scanAll appends every row to the slice dest points to and returns the
+// number of rows.
+func scanAll(rows *sql.Rows, dest interface{}) (int, error) {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.Elem().Kind() != reflect.Slice {
+		return 0, fmt.Errorf("mysqlz: Find needs a pointer to a slice, got %T", dest)
+	}
+	slice := dv.Elem()
+	elem := slice.Type().Elem()
+	isPtr := elem.Kind() == reflect.Ptr
+	if isPtr {
+		elem = elem.Elem()
+	}
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	slice.SetLen(0)
+	for rows.Next() {
+		item := reflect.New(elem)
+		if err := scanRow(rows, cols, item); err != nil {
+			return slice.Len(), err
+		}
+		if isPtr {
+			slice.Set(reflect.Append(slice, item))
+		} else {
+			slice.Set(reflect.Append(slice, item.Elem()))
+		}
+	}
+	return slice.Len(), rows.Err()
+}
+
+// Iterator streams the rows of a query, for result sets too large to load
+// at once. Close must be called when done.
+type Iterator struct {
+	rows *sql.Rows
+	cols []string
+	n    int64
+	done func(n int64, err error)
+}
+
+// Next prepares the next row for Scan and reports whether there is one.
+func (it *Iterator) Next() bool {
+	if !it.rows.Next() {
+		return false
+	}
+	it.n++
+	return true
+}
+
+// Scan copies the current row into dest, see Model.FindOne.
+func (it *Iterator) Scan(dest interface{}) error {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return errors.New("mysqlz: Scan needs a non-nil pointer")
+	}
+	return scanRow(it.rows, it.cols, dv)
+}
+
+// Columns returns the column names of the result.
+func (it *Iterator) Columns() []string {
+	return it.cols
+}
+
+// Err returns the error, if any, that ended the iteration.
+func (it *Iterator) Err() error {
+	return it.rows.Err()
+}
+
+func (it *Iterator) Close() error {
+	err := it.rows.Close()
+	if it.done != nil {
+		it.done(it.n, it.rows.Err())
+		it.done = nil
+	}
+	return err
+}
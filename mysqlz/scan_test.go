@@ -0,0 +1,88 @@
+package mysqlz
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type scanBase struct {
+	ID      int64 `db:"id"`
+	Created time.Time
+}
+
+type scanUser struct {
+	scanBase
+	UserName string
+	Nick     *string
+	Score    sql.NullFloat64 `db:"score"`
+	Avatar   []byte
+	Ignored  string `db:"-"`
+	HTTPAddr string
+	secret   string
+}
+
+func TestStructFields(t *testing.T) {
+	fields := structFields(reflect.TypeOf(scanUser{}))
+	want := map[string][]int{
+		"id":        {0, 0},
+		"created":   {0, 1},
+		"user_name": {1},
+		"nick":      {2},
+		"score":     {3},
+		"avatar":    {4},
+		"http_addr": {6},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("structFields = %v, want %v", fields, want)
+	}
+}
+
+func TestScanTargets(t *testing.T) {
+	var u scanUser
+	v := reflect.ValueOf(&u).Elem()
+	targets := scanTargets(v, []string{"ID", "created", "nick", "unknown"}, structFields(v.Type()))
+
+	if targets[0] != &u.ID || targets[2] != &u.Nick {
+		t.Errorf("targets = %v", targets)
+	}
+	if err := targets[1].(sql.Scanner).Scan([]byte("2017-03-04 05:06:07")); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2017, 3, 4, 5, 6, 7, 0, time.Local); !u.Created.Equal(want) {
+		t.Errorf("Created = %v", u.Created)
+	}
+	if err := targets[1].(sql.Scanner).Scan(nil); err != nil || !u.Created.IsZero() {
+		t.Errorf("Scan(nil) = %v, %v", err, u.Created)
+	}
+}
+
+func TestTimeScannerPtr(t *testing.T) {
+	var p *time.Time
+	ts := timeScanner{reflect.ValueOf(&p).Elem()}
+	now := time.Now()
+	if err := ts.Scan(now); err != nil || p == nil || !p.Equal(now) {
+		t.Errorf("Scan(time) = %v, %v", err, p)
+	}
+	if err := ts.Scan(nil); err != nil || p != nil {
+		t.Errorf("Scan(nil) = %v, %v", err, p)
+	}
+	if err := ts.Scan("garbage"); err == nil {
+		t.Error("Scan(garbage) succeeded")
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for in, want := range map[string]string{
+		"ID":        "id",
+		"UserID":    "user_id",
+		"HTTPAddr":  "http_addr",
+		"LiveRoom":  "live_room",
+		"already_x": "already_x",
+	} {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
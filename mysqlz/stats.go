@@ -0,0 +1,126 @@
+package mysqlz
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"litego/monitor"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the buckets of a LatencyHistogram.
+var LatencyBuckets = []time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second,
+}
+
+// LatencyHistogram is a Hook counting the latency of every statement in
+// LatencyBuckets, per statement text. Statements use placeholders, so the
+// text identifies the query and not its args.
+type LatencyHistogram struct {
+	mu    sync.Mutex
+	stats map[string]*LatencyStats
+}
+
+// LatencyStats is the histogram of one statement. Buckets[i] counts the
+// calls taking at most LatencyBuckets[i], the last one those taking longer.
+type LatencyStats struct {
+	Count   int64         `json:"count"`
+	Errors  int64         `json:"errors"`
+	Total   time.Duration `json:"total_ns"`
+	Max     time.Duration `json:"max_ns"`
+	Buckets []int64       `json:"buckets"`
+}
+
+func NewLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{stats: make(map[string]*LatencyStats)}
+}
+
+func (h *LatencyHistogram) BeforeQuery(ctx context.Context, e *QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *LatencyHistogram) AfterQuery(ctx context.Context, e *QueryEvent) {
+	key := strings.Join(strings.Fields(e.SQL), " ")
+	i := 0
+	for i < len(LatencyBuckets) && e.Duration > LatencyBuckets[i] {
+		i++
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.stats[key]
+	if !ok {
+		s = &LatencyStats{Buckets: make([]int64, len(LatencyBuckets)+1)}
+		h.stats[key] = s
+	}
+	s.Count++
+	if e.Err != nil {
+		s.Errors++
+	}
+	s.Total += e.Duration
+	if e.Duration > s.Max {
+		s.Max = e.Duration
+	}
+	s.Buckets[i]++
+}
+
+// Snapshot returns a copy of the histograms by statement.
+func (h *LatencyHistogram) Snapshot() map[string]LatencyStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	snap := make(map[string]LatencyStats, len(h.stats))
+	for key, s := range h.stats {
+		c := *s
+		c.Buckets = append([]int64(nil), s.Buckets...)
+		snap[key] = c
+	}
+	return snap
+}
+
+// Reset drops all counts.
+func (h *LatencyHistogram) Reset() {
+	h.mu.Lock()
+	h.stats = make(map[string]*LatencyStats)
+	h.mu.Unlock()
+}
+
+// ServeHTTP writes the Snapshot as JSON.
+func (h *LatencyHistogram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.Snapshot())
+}
+
+// Stats returns the connection statistics of the pool.
+func (m *MysqlConnPool) Stats() sql.DBStats {
+	return m.dbconnpool.Stats()
+}
+
+// ServeHTTP writes the Stats of the pool as JSON.
+func (m *MysqlConnPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, m.Stats())
+}
+
+// Publish adds the pool statistics to the monitor server as
+// /mysqlz/name/stats and, if hist is not nil, its latencies as
+// /mysqlz/name/latency. monitor.Init must have been called.
+func (m *MysqlConnPool) Publish(name string, hist *LatencyHistogram) {
+	monitor.AddManageFunc("/mysqlz/"+name+"/stats", m.ServeHTTP)
+	if hist != nil {
+		monitor.AddManageFunc("/mysqlz/"+name+"/latency", hist.ServeHTTP)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.Write(data)
+}
@@ -0,0 +1,158 @@
+package mysqlz
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultTxRetries is how often Tx reruns a transaction that failed with a
+// deadlock or lock wait timeout.
+var DefaultTxRetries = 3
+
+// TxOptions configures a transaction started by TxWith.
+type TxOptions struct {
+	Isolation sql.IsolationLevel // sql.LevelDefault uses the server setting
+	ReadOnly  bool
+	Retries   int // reruns after a deadlock or lock wait timeout, <0 for none
+}
+
+// TxModel is an open transaction. Its Models run their statements inside
+// it, bound to the ctx given to Tx; it must not be used after the function
+// given to Tx returns.
+type TxModel struct {
+	tx      *sql.Tx
+	ctx     context.Context
+	timeout time.Duration
+	hooks   []Hook
+	depth   int
+	writes  *txWrites
+}
+
+// txWrites collects the tables written by a transaction, to invalidate
+// them in the cache once it commits.
+type txWrites struct {
+	mu     sync.Mutex
+	all    bool
+	tables []string
+}
+
+// add records tables, or every table for nil.
+func (w *txWrites) add(tables []string) {
+	w.mu.Lock()
+	if tables == nil {
+		w.all = true
+	}
+	w.tables = append(w.tables, tables...)
+	w.mu.Unlock()
+}
+
+// Tx runs fn in a transaction with the server's isolation level and
+// DefaultTxRetries, see TxWith.
+func (m *MysqlConnPool) Tx(ctx context.Context, fn func(tx *TxModel) error) error {
+	return m.TxWith(ctx, TxOptions{Retries: DefaultTxRetries}, fn)
+}
+
+// TxWith runs fn in a transaction. It commits if fn returns nil and rolls
+// back if fn returns an error or panics, re-raising the panic. When fn or
+// the commit fails with ErrDeadlock or ErrLockWaitTimeout, the whole
+// transaction is rerun up to opts.Retries times, so fn must not have side
+// effects outside the database.
+func (m *MysqlConnPool) TxWith(ctx context.Context, opts TxOptions, fn func(tx *TxModel) error) error {
+	if m.dbconnpool == nil {
+		return ErrNotConnected
+	}
+	for attempt := 0; ; attempt++ {
+		err := m.runTx(ctx, opts, fn)
+		if err == nil || attempt >= opts.Retries || !isRetryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt+1) * 10 * time.Millisecond):
+		}
+	}
+}
+
+func (m *MysqlConnPool) runTx(ctx context.Context, opts TxOptions, fn func(tx *TxModel) error) (err error) {
+	tx, err := m.dbconnpool.BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return wrapError("BEGIN", nil, err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	writes := new(txWrites)
+	if err := fn(&TxModel{tx: tx, ctx: ctx, timeout: m.timeout, hooks: m.hooks, writes: writes}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return wrapError("COMMIT", nil, err)
+	}
+	if m.cache != nil {
+		if writes.all {
+			m.cache.Purge()
+		} else if len(writes.tables) > 0 {
+			m.cache.Invalidate(writes.tables...)
+		}
+	}
+	return nil
+}
+
+func isRetryable(err error) bool {
+	return errors.Is(err, ErrDeadlock) || errors.Is(err, ErrLockWaitTimeout)
+}
+
+// GetModel returns a Model running inside the transaction.
+func (t *TxModel) GetModel() *Model {
+	return &Model{run: t, ctx: t.ctx, timeout: t.timeout, hooks: t.hooks, invalidate: t.writes.add}
+}
+
+// GetTx returns the underlying transaction.
+func (t *TxModel) GetTx() *sql.Tx {
+	return t.tx
+}
+
+// Tx runs fn in a nested transaction backed by a savepoint: an error or
+// panic from fn rolls back only its own statements. A deadlock still
+// aborts the whole transaction and is retried by the outermost Tx.
+func (t *TxModel) Tx(fn func(tx *TxModel) error) (err error) {
+	sp := fmt.Sprintf("litego_sp%d", t.depth+1)
+	if _, err := t.ExecContext(t.ctx, "SAVEPOINT "+sp); err != nil {
+		return wrapError("SAVEPOINT "+sp, nil, err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			t.ExecContext(t.ctx, "ROLLBACK TO SAVEPOINT "+sp)
+			panic(p)
+		}
+	}()
+	if err := fn(&TxModel{tx: t.tx, ctx: t.ctx, timeout: t.timeout, hooks: t.hooks, depth: t.depth + 1, writes: t.writes}); err != nil {
+		if isRetryable(err) {
+			// the server rolled back the whole transaction and its savepoints
+			return err
+		}
+		if _, rerr := t.ExecContext(t.ctx, "ROLLBACK TO SAVEPOINT "+sp); rerr != nil {
+			return wrapError("ROLLBACK TO SAVEPOINT "+sp, nil, rerr)
+		}
+		return err
+	}
+	_, err = t.ExecContext(t.ctx, "RELEASE SAVEPOINT "+sp)
+	return wrapError("RELEASE SAVEPOINT "+sp, nil, err)
+}
+
+func (t *TxModel) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, query, args...)
+}
+
+func (t *TxModel) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, query, args...)
+}
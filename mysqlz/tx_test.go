@@ -0,0 +1,35 @@
+package mysqlz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestIsRetryable(t *testing.T) {
+	for err, want := range map[error]bool{
+		wrapError("UPDATE t SET a = 1", nil, &mysql.MySQLError{Number: 1213}):               true,
+		wrapError("UPDATE t SET a = 1", nil, &mysql.MySQLError{Number: 1205}):               true,
+		fmt.Errorf("step 2: %w", wrapError("COMMIT", nil, &mysql.MySQLError{Number: 1213})): true,
+		wrapError("INSERT INTO t VALUES (1)", nil, &mysql.MySQLError{Number: 1062}):         false,
+		errors.New("boom"): false,
+	} {
+		if got := isRetryable(err); got != want {
+			t.Errorf("isRetryable(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
+
+func TestTxNotConnected(t *testing.T) {
+	var pool MysqlConnPool
+	err := pool.Tx(context.Background(), func(tx *TxModel) error {
+		t.Error("fn called without a connection")
+		return nil
+	})
+	if err != ErrNotConnected {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package mysqlz
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Upsert inserts values, or updates the row they collide with on a unique
+// key. The conflictCols identify the row and are not updated; every other
+// column of values is, and with SetSoftDelete a deleted row is restored.
+// It returns the id of the inserted or updated row when the Model has a
+// primary key.
+func (m *Model) Upsert(values map[string]interface{}, conflictCols ...string) (int, error) {
+	return m.UpsertContext(m.context(), values, conflictCols...)
+}
+
+func (m *Model) UpsertContext(ctx context.Context, values map[string]interface{}, conflictCols ...string) (int, error) {
+	keep := make(map[string]bool, len(conflictCols))
+	for _, col := range conflictCols {
+		keep[col] = true
+	}
+	update := make(map[string]interface{})
+	for col := range values {
+		if !keep[col] {
+			update[col] = Values(col)
+		}
+	}
+	if pk := m.primarykey; pk != "" {
+		// makes LastInsertId return the id of an updated row too
+		update[pk] = Raw("LAST_INSERT_ID(" + quoteIdent(pk) + ")")
+	} else if len(update) == 0 && len(conflictCols) > 0 {
+		update[conflictCols[0]] = Raw(quoteIdent(conflictCols[0]))
+	}
+	if m.softDelete != "" {
+		// a collision with a soft deleted row brings it back
+		update[m.softDelete] = nil
+	}
+	u := m.OnDuplicateKeyUpdate(update)
+	u.primarykey = "" // the key may be a conflict column
+	return u.InsertContext(ctx, values)
+}
+
+// SetSoftDelete turns on soft delete with the nullable DATETIME column col,
+// "deleted_at" if empty: Delete sets it instead of removing rows, and every
+// other statement skips the rows where it is set, see WithTrashed.
+func (m *Model) SetSoftDelete(col string) *Model {
+	if col == "" {
+		col = "deleted_at"
+	}
+	c := m.clone()
+	c.softDelete = col
+	return c
+}
+
+// WithTrashed includes soft deleted rows.
+func (m *Model) WithTrashed() *Model {
+	c := m.clone()
+	c.withTrashed = true
+	return c
+}
+
+// softDeleteCol returns the soft delete column, qualified by the table
+// unless that is aliased.
+func (m *Model) softDeleteCol() string {
+	if simpleIdent.MatchString(m.table) && !strings.Contains(m.softDelete, ".") {
+		return quoteIdent(m.table + "." + m.softDelete)
+	}
+	return quoteIdent(m.softDelete)
+}
+
+// condition returns the WHERE condition, with the soft delete filter.
+func (m *Model) condition() Expr {
+	if m.softDelete == "" || m.withTrashed {
+		return m.where
+	}
+	return And(m.where, Expr{sql: m.softDeleteCol() + " IS NULL"})
+}
+
+// ForceDelete removes the rows matching where even with soft delete on.
+func (m *Model) ForceDelete(where string, args ...interface{}) (int, error) {
+	return m.ForceDeleteContext(m.context(), where, args...)
+}
+
+func (m *Model) ForceDeleteContext(ctx context.Context, where string, args ...interface{}) (int, error) {
+	c := m.clone()
+	c.softDelete = ""
+	return c.DeleteContext(ctx, where, args...)
+}
+
+// Restore clears the soft delete column of the rows matching where.
+func (m *Model) Restore(where string, args ...interface{}) (int, error) {
+	return m.RestoreContext(m.context(), where, args...)
+}
+
+func (m *Model) RestoreContext(ctx context.Context, where string, args ...interface{}) (int, error) {
+	if m.softDelete == "" {
+		return 0, fmt.Errorf("mysqlz: Restore needs SetSoftDelete")
+	}
+	u := m.WithTrashed().WhereExpr(And(Raw(where, args...), Expr{sql: m.softDeleteCol() + " IS NOT NULL"}))
+	query, args := u.updateSQL(map[string]interface{}{m.softDelete: nil})
+	return u.affected(ctx, query, args)
+}
+
+// SetVersion turns on optimistic locking with the integer column col,
+// "version" if empty: Update needs a Where and the version the row was
+// read with in its param, increments it and fails with a ConflictError if the row no
+// longer has that version.
+func (m *Model) SetVersion(col string) *Model {
+	if col == "" {
+		col = "version"
+	}
+	c := m.clone()
+	c.version = col
+	return c
+}
+
+// versioned returns the Model and param of a versioned Update.
+func (m *Model) versioned(param map[string]interface{}) (*Model, map[string]interface{}, error) {
+	if m.where.sql == "" {
+		return nil, nil, fmt.Errorf("mysqlz: Update of versioned %s needs a Where", m.table)
+	}
+	old, ok := param[m.version]
+	if !ok {
+		return nil, nil, fmt.Errorf("mysqlz: Update of versioned %s needs the %s column", m.table, m.version)
+	}
+	set := make(map[string]interface{}, len(param))
+	for k, v := range param {
+		set[k] = v
+	}
+	set[m.version] = Raw(quoteIdent(m.version) + " + 1")
+	return m.WhereExpr(And(m.where, Raw(quoteIdent(m.version)+" = ?", old))), set, nil
+}
@@ -0,0 +1,108 @@
+package mysqlz
+
+import (
+	"database/sql/driver"
+	"errors"
+	"litego/mysqlz/mysqlztest"
+	"testing"
+)
+
+func TestUpsert(t *testing.T) {
+	fake := mysqlztest.New()
+	stats := NewMysqlConnPoolFromDB(fake.DB()).GetModel().SetTable("stat").SetPrimaryKey("id")
+
+	fake.Expect("INSERT INTO `stat` (`day`, `hits`, `room`) VALUES (?, ?, ?)"+
+		" ON DUPLICATE KEY UPDATE `hits` = VALUES(`hits`), `id` = LAST_INSERT_ID(`id`)").
+		WithArgs("2017-03-04", 9, 210150).WillReturnResult(12, 2)
+	id, err := stats.Upsert(map[string]interface{}{"day": "2017-03-04", "room": 210150, "hits": 9}, "day", "room")
+	if err != nil || id != 12 {
+		t.Errorf("Upsert = %d, %v", id, err)
+	}
+
+	fake.Expect("INSERT INTO `tag` (`name`) VALUES (?) ON DUPLICATE KEY UPDATE `name` = `name`").WithArgs("go")
+	if _, err := stats.SetTable("tag").SetPrimaryKey("").Upsert(map[string]interface{}{"name": "go"}, "name"); err != nil {
+		t.Error(err)
+	}
+
+	fake.Expect("INSERT INTO `user` (`name`) VALUES (?)"+
+		" ON DUPLICATE KEY UPDATE `deleted_at` = ?, `id` = LAST_INSERT_ID(`id`)").
+		WithArgs("ann", nil).WillReturnResult(5, 2)
+	users := stats.SetTable("user").SetSoftDelete("")
+	if id, err := users.Upsert(map[string]interface{}{"name": "ann"}, "name"); err != nil || id != 5 {
+		t.Errorf("Upsert of a soft deleted row = %d, %v", id, err)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	fake := mysqlztest.New()
+	users := NewMysqlConnPoolFromDB(fake.DB()).GetModel().SetTable("user").SetSoftDelete("")
+
+	fake.Expect("SELECT * FROM `user` WHERE (id = ? OR vip = ?) AND `user`.`deleted_at` IS NULL").WithArgs(1, true)
+	fake.Expect("SELECT COUNT(*) FROM `user` WHERE `user`.`deleted_at` IS NULL").
+		WillReturnRows([]string{"COUNT(*)"}, []driver.Value{int64(4)})
+	fake.Expect("SELECT * FROM `user` WHERE id = ?").WithArgs(1)
+	fake.Expect("UPDATE `user` SET `deleted_at` = NOW() WHERE id = ? AND `user`.`deleted_at` IS NULL").
+		WithArgs(1).WillReturnResult(0, 1)
+	fake.Expect("UPDATE `user` SET `deleted_at` = NOW() WHERE id = ? AND `user`.`deleted_at` IS NULL").WithArgs(2)
+	fake.Expect("UPDATE `user` SET `deleted_at` = ? WHERE id = ? AND `user`.`deleted_at` IS NOT NULL").
+		WithArgs(nil, 1).WillReturnResult(0, 1)
+	fake.Expect("DELETE FROM `user` WHERE id = ?").WithArgs(1).WillReturnResult(0, 1)
+
+	users.Where("id = ? OR vip = ?", 1, true).FindAll()
+	if n, err := users.Count(); n != 4 || err != nil {
+		t.Errorf("Count = %d, %v", n, err)
+	}
+	users.WithTrashed().Where("id = ?", 1).FindAll()
+	if n, err := users.Delete("id = ?", 1); n != 1 || err != nil {
+		t.Errorf("Delete = %d, %v", n, err)
+	}
+	if _, err := users.Delete("id = ?", 2); err != ErrNotFound {
+		t.Errorf("Delete of a missing row: err = %v", err)
+	}
+	if n, err := users.Restore("id = ?", 1); n != 1 || err != nil {
+		t.Errorf("Restore = %d, %v", n, err)
+	}
+	if n, err := users.ForceDelete("id = ?", 1); n != 1 || err != nil {
+		t.Errorf("ForceDelete = %d, %v", n, err)
+	}
+	// an empty condition would mark or remove every row
+	if _, err := users.Delete(""); err == nil {
+		t.Error("Delete without a condition succeeded")
+	}
+	if _, err := users.ForceDelete(" "); err == nil {
+		t.Error("ForceDelete without a condition succeeded")
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOptimisticLock(t *testing.T) {
+	fake := mysqlztest.New()
+	rooms := NewMysqlConnPoolFromDB(fake.DB()).GetModel().SetTable("room").SetVersion("")
+	update := "UPDATE `room` SET `title` = ?, `version` = `version` + 1 WHERE id = ? AND `version` = ?"
+
+	fake.Expect(update).WithArgs("a", 7, 3).WillReturnResult(0, 1)
+	fake.Expect(update).WithArgs("b", 7, 3)
+
+	if n, err := rooms.Where("id = ?", 7).Update(map[string]interface{}{"title": "a", "version": 3}); n != 1 || err != nil {
+		t.Errorf("Update = %d, %v", n, err)
+	}
+	_, err := rooms.Where("id = ?", 7).Update(map[string]interface{}{"title": "b", "version": 3})
+	var conflict *ConflictError
+	if !errors.Is(err, ErrConflict) || !errors.As(err, &conflict) || conflict.Version != 3 {
+		t.Errorf("stale Update: err = %v", err)
+	}
+	if _, err := rooms.Where("id = ?", 7).Update(map[string]interface{}{"title": "c"}); err == nil {
+		t.Error("Update without the version accepted")
+	}
+	if _, err := rooms.Update(map[string]interface{}{"title": "c", "version": 3}); err == nil {
+		t.Error("Update without a Where accepted")
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}